@@ -0,0 +1,75 @@
+package aiff
+
+import (
+	"fmt"
+	"io"
+)
+
+// AESEmphasis is the pre-emphasis indicated in the AES channel status data.
+type AESEmphasis int
+
+const (
+	// EmphasisNotIndicated means the emphasis is unknown, or that the
+	// channel status isn't in the professional format.
+	EmphasisNotIndicated AESEmphasis = iota
+	// EmphasisNone means no pre-emphasis was applied.
+	EmphasisNone
+	// Emphasis5015 is the 50/15µs pre-emphasis.
+	Emphasis5015
+	// EmphasisJ17 is the CCITT J.17 pre-emphasis.
+	EmphasisJ17
+)
+
+// parseAESDChunk reads the AES channel status data of the AESD chunk.
+func (d *Decoder) parseAESDChunk(size uint32) error {
+	if size < 24 {
+		return d.jumpTo(int(size))
+	}
+	if _, err := io.ReadFull(d.r, d.AESChannelStatus[:]); err != nil {
+		return fmt.Errorf("AESD chunk failed to parse - %s", err)
+	}
+	d.hasAESD = true
+	return d.jumpTo(int(size) - 24)
+}
+
+// aesProfessional reports whether the channel status data uses the
+// professional format, the only one the helpers below decode.
+func (d *Decoder) aesProfessional() bool {
+	return d.hasAESD && d.AESChannelStatus[0]&0x01 != 0
+}
+
+// AESSampleRate returns the sample rate indicated in the AES channel status
+// data, or 0 when not indicated.
+func (d *Decoder) AESSampleRate() int {
+	if !d.aesProfessional() {
+		return 0
+	}
+	// bits 6-7 of byte 0, bit 6 transmitted first
+	switch (d.AESChannelStatus[0] >> 6) & 0x03 {
+	case 2:
+		return 48000
+	case 1:
+		return 44100
+	case 3:
+		return 32000
+	}
+	return 0
+}
+
+// AESEmphasis returns the pre-emphasis indicated in the AES channel status
+// data.
+func (d *Decoder) AESEmphasis() AESEmphasis {
+	if !d.aesProfessional() {
+		return EmphasisNotIndicated
+	}
+	// bits 2-4 of byte 0, bit 2 transmitted first
+	switch (d.AESChannelStatus[0] >> 2) & 0x07 {
+	case 1:
+		return EmphasisNone
+	case 3:
+		return Emphasis5015
+	case 7:
+		return EmphasisJ17
+	}
+	return EmphasisNotIndicated
+}
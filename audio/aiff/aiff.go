@@ -1,6 +1,10 @@
 package aiff
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/mattetti/exp/audio"
+)
 
 var (
 	formID = [4]byte{'F', 'O', 'R', 'M'}
@@ -8,6 +12,21 @@ var (
 	aifcID = [4]byte{'A', 'I', 'F', 'C'}
 	commID = [4]byte{'C', 'O', 'M', 'M'}
 	ssndID = [4]byte{'S', 'S', 'N', 'D'}
+	markID = [4]byte{'M', 'A', 'R', 'K'}
+	instID = [4]byte{'I', 'N', 'S', 'T'}
+	aesdID = [4]byte{'A', 'E', 'S', 'D'}
+	fverID = [4]byte{'F', 'V', 'E', 'R'}
+	peakID = [4]byte{'P', 'E', 'A', 'K'}
+	midiID = [4]byte{'M', 'I', 'D', 'I'}
+	// Apple Loops beat information
+	bascID = [4]byte{'b', 'a', 's', 'c'}
+	// filler chunks used to align data
+	fllrID = [4]byte{'F', 'L', 'L', 'R'}
+	junkID = [4]byte{'J', 'U', 'N', 'K'}
+	// RIFF based containers, used by WAV files
+	riffID = [4]byte{'R', 'I', 'F', 'F'}
+	rifxID = [4]byte{'R', 'I', 'F', 'X'}
+	rf64ID = [4]byte{'R', 'F', '6', '4'}
 
 	// AIFC encodings
 	encNone = [4]byte{'N', 'O', 'N', 'E'}
@@ -35,8 +54,28 @@ var (
 	encGsm  = [4]byte{'G', 'S', 'M', ' '}
 	encIma4 = [4]byte{'i', 'm', 'a', '4'}
 
+	// 1-bit Direct Stream Digital data
+	encDSD = [4]byte{'D', 'S', 'D', ' '}
+	encDsd = [4]byte{'d', 's', 'd', ' '}
+
 	// ErrFmtNotSupported is a generic error reporting an unknown format.
 	ErrFmtNotSupported = errors.New("format not supported")
 	// ErrUnexpectedData is a generic error reporting that the parser encountered unexpected data.
 	ErrUnexpectedData = errors.New("unexpected data content")
+	// ErrTruncated is reported when the file is shorter than its FORM size.
+	ErrTruncated = errors.New("truncated file")
+	// ErrOverflow is reported when a processed sample exceeds the range
+	// of its bit depth and the OverflowError policy is used.
+	ErrOverflow = errors.New("sample overflow")
+	// ErrNestedForm is reported when a FORM chunk is found inside the main
+	// FORM and Decoder.FlattenNestedForms isn't set.
+	ErrNestedForm = errors.New("nested FORM chunk")
+	// ErrUnsupportedDSD is reported for files holding DSD (Direct Stream
+	// Digital) data instead of PCM, which would decode as noise.
+	ErrUnsupportedDSD = errors.New("DSD data not supported, convert the file to PCM first")
 )
+
+func init() {
+	audio.RegisterFormat("aiff", "FORM????AIFF", Decode)
+	audio.RegisterFormat("aifc", "FORM????AIFC", Decode)
+}
@@ -0,0 +1,334 @@
+package aiff
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// ClipRegion is a range of sample frames of a channel where the signal
+// is clipped.
+type ClipRegion struct {
+	Channel int
+	// Start is the first clipped frame, End the frame following the
+	// last clipped one.
+	Start int64
+	End   int64
+}
+
+// DetectClipping returns the regions where consecutive samples of a channel
+// sit at full scale for at least minRun frames.
+func DetectClipping(c audio.Clip, minRun int) ([]ClipRegion, error) {
+	if minRun < 1 {
+		minRun = 1
+	}
+	max, min := maxSample(c.BitDepth()), minSample(c.BitDepth())
+	// start of the current full scale run per channel, -1 if none
+	runs := make([]int64, c.Channels())
+	for i := range runs {
+		runs[i] = -1
+	}
+	var regions []ClipRegion
+	closeRun := func(ch int, end int64) {
+		if runs[ch] >= 0 && end-runs[ch] >= int64(minRun) {
+			regions = append(regions, ClipRegion{Channel: ch, Start: runs[ch], End: end})
+		}
+		runs[ch] = -1
+	}
+
+	var frames int64
+	err := eachFrame(c, func(i int64, frame []int) error {
+		for ch, v := range frame {
+			if v >= max || v <= min {
+				if runs[ch] < 0 {
+					runs[ch] = i
+				}
+				continue
+			}
+			closeRun(ch, i)
+		}
+		frames = i + 1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for ch := range runs {
+		closeRun(ch, frames)
+	}
+	return regions, nil
+}
+
+// Fingerprint returns the SHA-256 of the decoded samples of c. Each sample is
+// hashed as a big endian int32 so the fingerprint only depends on the audio
+// content, not on the container, its metadata chunks or the sample padding.
+// The read position of c is restored afterwards.
+func Fingerprint(c audio.Clip) ([32]byte, error) {
+	var sum [32]byte
+	h := sha256.New()
+	buf := make([]byte, 4)
+	err := eachFrame(c, func(i int64, frame []int) error {
+		for _, v := range frame {
+			binary.BigEndian.PutUint32(buf, uint32(int32(v)))
+			h.Write(buf)
+		}
+		return nil
+	})
+	if err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// Stats holds the levels of a signal, per channel. Levels are normalized to
+// the full scale of the source bit depth.
+type Stats struct {
+	Frames int64
+	Peak   []float64
+	RMS    []float64
+}
+
+// StatsStream computes the stats of the big endian PCM data read from r,
+// consuming it once. Contrary to the functions working on clips, it
+// doesn't need to seek and can be used on pipes.
+func StatsStream(r io.Reader, info audio.FrameInfo) (Stats, error) {
+	stats := Stats{
+		Peak: make([]float64, info.Channels),
+		RMS:  make([]float64, info.Channels),
+	}
+	frameSize := info.BytesPerFrame()
+	if frameSize == 0 {
+		return stats, ErrFmtNotSupported
+	}
+	fullScale := float64(maxSample(info.BitDepth) + 1)
+	squares := make([]float64, info.Channels)
+	blockFrames := defaultBlockSize / frameSize
+	if blockFrames < 1 {
+		blockFrames = 1
+	}
+	block := make([][]int, blockFrames)
+	for {
+		n, err := readFrames(r, block, info.Channels, info.BitDepth)
+		for _, frame := range block[:n] {
+			for ch, v := range frame {
+				a := float64(v) / fullScale
+				squares[ch] += a * a
+				if a = math.Abs(a); a > stats.Peak[ch] {
+					stats.Peak[ch] = a
+				}
+			}
+		}
+		stats.Frames += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+	if stats.Frames > 0 {
+		for ch, sum := range squares {
+			stats.RMS[ch] = math.Sqrt(sum / float64(stats.Frames))
+		}
+	}
+	return stats, nil
+}
+
+// MeterStream reads c from its current position and sends, for every block
+// of blockFrames frames, the peak level of each channel normalized to full
+// scale. It is meant to drive level meters while the clip is being played.
+// out is closed once the end of the clip is reached or reading fails.
+func MeterStream(c audio.Clip, blockFrames int, out chan<- []float64) error {
+	defer close(out)
+	if blockFrames < 1 {
+		blockFrames = 1
+	}
+	pos, err := c.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	clip := asClip(c)
+	if _, err := clip.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	fullScale := float64(maxSample(c.BitDepth()) + 1)
+	block := make([][]int, blockFrames)
+	for {
+		n, err := clip.ReadFrames(block)
+		if n > 0 {
+			peaks := make([]float64, c.Channels())
+			for _, frame := range block[:n] {
+				for ch, v := range frame {
+					if a := math.Abs(float64(v) / fullScale); a > peaks[ch] {
+						peaks[ch] = a
+					}
+				}
+			}
+			out <- peaks
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Waveform returns the minimum and maximum amplitude, normalized to full
+// scale, of the downmixed signal of c for each of the buckets evenly
+// splitting the clip. It reads the clip in a single pass and is meant to draw
+// waveform overviews. The read position of c is restored afterwards.
+func Waveform(c audio.Clip, buckets int) ([][2]float64, error) {
+	w, err := waveform(c, buckets, false)
+	if err != nil {
+		return nil, err
+	}
+	return w[0], nil
+}
+
+// WaveformChannels is like Waveform but returns the buckets of each channel
+// instead of downmixing them.
+func WaveformChannels(c audio.Clip, buckets int) ([][][2]float64, error) {
+	return waveform(c, buckets, true)
+}
+
+// waveform computes the min/max buckets of the channels of c, or of their
+// average when perChannel is false.
+func waveform(c audio.Clip, buckets int, perChannel bool) ([][][2]float64, error) {
+	if buckets < 1 {
+		return nil, fmt.Errorf("invalid bucket count %d", buckets)
+	}
+	frameSize := int64(c.FrameInfo().BytesPerFrame())
+	if frameSize == 0 {
+		return nil, ErrFmtNotSupported
+	}
+	frames := c.Size() / frameSize
+	channels := 1
+	if perChannel {
+		channels = c.Channels()
+	}
+	w := make([][][2]float64, channels)
+	for ch := range w {
+		w[ch] = make([][2]float64, buckets)
+	}
+	fullScale := float64(maxSample(c.BitDepth()) + 1)
+	// set buckets are tracked so the first value initializes them
+	seen := make([]bool, buckets)
+	err := eachFrame(c, func(i int64, frame []int) error {
+		if i >= frames {
+			return nil
+		}
+		b := int(i * int64(buckets) / frames)
+		first := !seen[b]
+		seen[b] = true
+		for ch := range w {
+			var v float64
+			if perChannel {
+				v = float64(frame[ch]) / fullScale
+			} else {
+				for _, s := range frame {
+					v += float64(s)
+				}
+				v /= float64(len(frame)) * fullScale
+			}
+			if first || v < w[ch][b][0] {
+				w[ch][b][0] = v
+			}
+			if first || v > w[ch][b][1] {
+				w[ch][b][1] = v
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// EffectiveBitDepth returns the resolution actually used by the samples of
+// c, ignoring the low bits that are zero in every sample. For instance a
+// 24-bit clip upconverted from 16-bit has an effective bit depth of 16 and
+// can be converted back without loss. A silent clip returns 0.
+// The read position of c is restored afterwards.
+func EffectiveBitDepth(c audio.Clip) (int, error) {
+	var used uint32
+	err := eachFrame(c, func(i int64, frame []int) error {
+		for _, v := range frame {
+			used |= uint32(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if used == 0 {
+		return 0, nil
+	}
+	return c.BitDepth() - bits.TrailingZeros32(used), nil
+}
+
+// ZeroCrossings returns, for each channel, the number of times the signal
+// changes sign over the whole clip. Zero samples don't count as a sign, a
+// signal going from positive to zero to negative crosses zero once.
+// The read position of c is restored afterwards.
+func ZeroCrossings(c audio.Clip) ([]int, error) {
+	crossings := make([]int, c.Channels())
+	// sign of the last non zero sample of each channel
+	signs := make([]int, c.Channels())
+	err := eachFrame(c, func(i int64, frame []int) error {
+		for ch, v := range frame {
+			var sign int
+			switch {
+			case v > 0:
+				sign = 1
+			case v < 0:
+				sign = -1
+			default:
+				continue
+			}
+			if signs[ch] != 0 && sign != signs[ch] {
+				crossings[ch]++
+			}
+			signs[ch] = sign
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return crossings, nil
+}
+
+// errNotSilent stops the scan of IsSilent on the first loud sample.
+var errNotSilent = errors.New("not silent")
+
+// IsSilent reports whether all the samples of c stay below thresholdDB,
+// relative to full scale. The scan stops on the first sample reaching the
+// threshold. The read position of c is restored afterwards.
+func IsSilent(c audio.Clip, thresholdDB float64) (bool, error) {
+	threshold := audio.DBToAmplitude(thresholdDB) * float64(maxSample(c.BitDepth())+1)
+	err := eachFrame(c, func(i int64, frame []int) error {
+		for _, v := range frame {
+			if math.Abs(float64(v)) >= threshold {
+				return errNotSilent
+			}
+		}
+		return nil
+	})
+	if err == errNotSilent {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
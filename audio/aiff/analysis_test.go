@@ -0,0 +1,236 @@
+package aiff
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+)
+
+func TestDetectClipping(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := make([][]int, 100)
+	for i := range frames {
+		frames[i] = []int{i * 100, -i * 100}
+	}
+	// a plateau on the right channel, a short spike on the left one
+	for i := 40; i < 50; i++ {
+		frames[i][1] = -32768
+	}
+	frames[70][0] = 32767
+	frames[71][0] = 32767
+	// a run reaching the end of the clip
+	frames[98][0], frames[99][0] = 32767, 32767
+
+	regions, err := DetectClipping(newMemClip(frames, info), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ClipRegion{
+		{Channel: 1, Start: 40, End: 50},
+		{Channel: 0, Start: 70, End: 72},
+		{Channel: 0, Start: 98, End: 100},
+	}
+	if !reflect.DeepEqual(regions, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, regions)
+	}
+
+	regions, err = DetectClipping(newMemClip(frames, info), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(regions, expected[:1]) {
+		t.Fatalf("expected only the plateau, got %+v", regions)
+	}
+}
+
+func TestStatsStream(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := make([][]int, 10000)
+	for i := range frames {
+		// a half scale square wave on the left, silence on the right
+		frames[i] = []int{16384, 0}
+		if i%2 == 1 {
+			frames[i][0] = -16384
+		}
+	}
+	data, err := encodeFrames(frames, info, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a pipe can't seek
+	r, w := io.Pipe()
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+	stats, err := StatsStream(r, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Frames != 10000 {
+		t.Fatalf("expected 10000 frames, got %d", stats.Frames)
+	}
+	if stats.Peak[0] != 0.5 || stats.RMS[0] != 0.5 || stats.Peak[1] != 0 || stats.RMS[1] != 0 {
+		t.Fatalf("expected peaks of 0.5 and 0 and matching RMS, got %+v", stats)
+	}
+}
+
+func TestMeterStream(t *testing.T) {
+	// a ramp rising to full scale, then held there
+	frames := make([][]int, 1200)
+	for i := range frames {
+		v := i * 32
+		if v > 32767 {
+			v = 32767
+		}
+		frames[i] = []int{v, -v}
+	}
+	c := newMemClip(frames, audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000})
+	out := make(chan []float64)
+	errc := make(chan error, 1)
+	go func() { errc <- MeterStream(c, 100, out) }()
+	var blocks [][]float64
+	for peaks := range out {
+		blocks = append(blocks, peaks)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 12 {
+		t.Fatalf("expected 12 blocks, got %d", len(blocks))
+	}
+	for i, peaks := range blocks {
+		if len(peaks) != 2 || peaks[0] != peaks[1] {
+			t.Fatalf("block %d: expected matching peaks for both channels, got %v", i, peaks)
+		}
+		if peaks[0] > 1 {
+			t.Fatalf("block %d: peak %f above full scale", i, peaks[0])
+		}
+		if i > 0 && peaks[0] < blocks[i-1][0] {
+			t.Fatalf("block %d: peak %f lower than the previous %f", i, peaks[0], blocks[i-1][0])
+		}
+	}
+	if expected := float64(99*32) / 32768; blocks[0][0] != expected {
+		t.Fatalf("expected a first peak of %f, got %f", expected, blocks[0][0])
+	}
+	if last := blocks[len(blocks)-1][0]; last != float64(32767)/32768 {
+		t.Fatalf("expected the last blocks to peak at full scale, got %f", last)
+	}
+}
+
+func TestWaveform(t *testing.T) {
+	frames := make([][]int, 8)
+	for i := range frames {
+		frames[i] = []int{i * 4096, -i * 4096}
+	}
+	c := newMemClip(frames, audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000})
+	w, err := WaveformChannels(c, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b := 0; b < 4; b++ {
+		low, high := float64(2*b)/8, float64(2*b+1)/8
+		if expected := [2]float64{low, high}; w[0][b] != expected {
+			t.Fatalf("left bucket %d: expected %v, got %v", b, expected, w[0][b])
+		}
+		if expected := [2]float64{-high, -low}; w[1][b] != expected {
+			t.Fatalf("right bucket %d: expected %v, got %v", b, expected, w[1][b])
+		}
+	}
+
+	// the channels cancel each other once downmixed
+	mixed, err := Waveform(c, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := make([][2]float64, 4); !reflect.DeepEqual(mixed, expected) {
+		t.Fatalf("expected silent downmixed buckets, got %v", mixed)
+	}
+	if _, err := Waveform(c, 0); err == nil {
+		t.Fatal("expected an error for 0 buckets")
+	}
+}
+
+func TestEffectiveBitDepth(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 44100}
+	for _, tt := range []struct {
+		frames   [][]int
+		expected int
+	}{
+		// 16-bit values upconverted to 24 bits
+		{[][]int{{1 << 8, -3 << 8}, {32767 << 8, -32768 << 8}}, 16},
+		{[][]int{{1 << 8, 1}, {0, 0}}, 24},
+		{[][]int{{-1 << 20, 1 << 22}}, 4},
+		{[][]int{{0, 0}, {0, 0}}, 0},
+	} {
+		depth, err := EffectiveBitDepth(newMemClip(tt.frames, info))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if depth != tt.expected {
+			t.Fatalf("%v: expected an effective bit depth of %d, got %d", tt.frames, tt.expected, depth)
+		}
+	}
+}
+
+func TestZeroCrossings(t *testing.T) {
+	const rate, freq, seconds = 44100, 440, 2
+	frames := make([][]int, rate*seconds)
+	for i := range frames {
+		v := int(10000 * math.Sin(2*math.Pi*freq*float64(i)/rate+0.1))
+		frames[i] = []int{v, 0}
+	}
+	c := newMemClip(frames, audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: rate})
+	crossings, err := ZeroCrossings(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 2 * freq * seconds; crossings[0] < expected-2 || crossings[0] > expected+2 {
+		t.Fatalf("expected about %d crossings, got %d", expected, crossings[0])
+	}
+	if crossings[1] != 0 {
+		t.Fatalf("expected no crossings on the silent channel, got %d", crossings[1])
+	}
+}
+
+func TestIsSilent(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	frames := make([][]int, 10000)
+	for i := range frames {
+		frames[i] = []int{i % 3, -(i % 5)}
+	}
+	silent, err := IsSilent(newMemClip(frames, info), -60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !silent {
+		t.Fatal("expected a clip of low noise to be silent")
+	}
+
+	frames[len(frames)-2][1] = -20000
+	c := newMemClip(frames, info)
+	if _, err := c.Seek(8, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if silent, err = IsSilent(c, -60); err != nil || silent {
+		t.Fatalf("expected a loud sample near the end to be found, got %v (%v)", silent, err)
+	}
+	if pos, _ := c.Seek(0, io.SeekCurrent); pos != 8 {
+		t.Fatalf("expected the read position to be restored, got %d", pos)
+	}
+
+	// the scan stops on the first loud sample
+	frames[1][0] = 20000
+	c = newMemClip(frames, info)
+	counter := newCountingReadSeeker(c.r)
+	c.r = counter
+	if silent, err = IsSilent(c, -60); err != nil || silent {
+		t.Fatalf("expected a loud sample to be found, got %v (%v)", silent, err)
+	}
+	if read := counter.consumed(); read >= c.Size() {
+		t.Fatalf("expected the scan to stop early, read %d of %d bytes", read, c.Size())
+	}
+}
@@ -0,0 +1,95 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Append adds the passed frames at the end of the sound data of the AIFF
+// file f and updates the SSND, COMM and FORM sizes in place, which allows
+// recording to a file incrementally. Chunks following the SSND chunk are
+// moved after the new data. Only PCM data can be appended to.
+func Append(f io.ReadWriteSeeker, frames [][]int) error {
+	formStart, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	d := NewDecoder(f)
+	d.HeaderOnly = true
+	c, err := d.Decode()
+	if err != nil {
+		return err
+	}
+	if d.commSize == 0 || d.ssndStart == 0 {
+		return fmt.Errorf("%s - COMM or SSND chunk missing", ErrUnexpectedData)
+	}
+	// the decoder skipped the SSND header, read it now
+	if _, err := f.Seek(d.ssndStart-4, io.SeekStart); err != nil {
+		return err
+	}
+	var ssndSize uint32
+	if err := binary.Read(f, binary.BigEndian, &ssndSize); err != nil {
+		return err
+	}
+	if ssndSize < 8 {
+		return fmt.Errorf("%s - SSND chunk of %d bytes", ErrUnexpectedData, ssndSize)
+	}
+	var swap bool
+	switch d.sampleFormat() {
+	case pcmFormat(int(d.SampleSize)):
+	case pcmLEFormat(int(d.SampleSize)):
+		swap = true
+	default:
+		return fmt.Errorf("%s - can't append to %s data", ErrFmtNotSupported, d.Encoding)
+	}
+	data, err := encodeFrames(frames, c.FrameInfo(), swap)
+	if err != nil {
+		return err
+	}
+
+	// the chunks after the sound data are rewritten after the new data
+	dataEnd := d.ssndStart + int64(ssndSize)
+	tailStart := dataEnd + int64(ssndSize%2)
+	var tail []byte
+	if tailStart < d.formEnd {
+		tail = make([]byte, d.formEnd-tailStart)
+		if _, err := f.Seek(tailStart, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(f, tail); err != nil {
+			return err
+		}
+	}
+	ssndSize += uint32(len(data))
+	if ssndSize%2 == 1 {
+		data = append(data, 0)
+	}
+	if _, err := f.Seek(dataEnd, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if _, err := f.Write(tail); err != nil {
+		return err
+	}
+
+	formSize := uint32(dataEnd + int64(len(data)) + int64(len(tail)) - formStart - 8)
+	for _, field := range []struct {
+		pos   int64
+		value uint32
+	}{
+		{formStart + 4, formSize},
+		{d.commStart + 2, d.NumSampleFrames + uint32(len(frames))},
+		{d.ssndStart - 4, ssndSize},
+	} {
+		if _, err := f.Seek(field.pos, io.SeekStart); err != nil {
+			return err
+		}
+		if err := binary.Write(f, binary.BigEndian, field.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package aiff
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// Result holds the information decoded from one of the files passed to
+// DecodeBatch, or the error preventing it.
+type Result struct {
+	Path       string
+	Info       audio.FrameInfo
+	Duration   time.Duration
+	Markers    []Marker
+	Instrument *Instrument
+	// Err is set when the file couldn't be decoded, the other fields being
+	// left empty.
+	Err error
+}
+
+// DecodeBatch decodes the headers of the AIFF files at paths using up to
+// concurrency workers, the sound data itself isn't read. The results are in
+// the order of paths and a file failing to decode only sets the Err field
+// of its own result.
+func DecodeBatch(paths []string, concurrency int) ([]Result, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency %d", concurrency)
+	}
+	results := make([]Result, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = decodeInfo(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// decodeInfo decodes the headers of the file at path.
+func decodeInfo(path string) Result {
+	res := Result{Path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer f.Close()
+	d := NewDecoder(f)
+	d.HeaderOnly = true
+	c, err := d.Decode()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	duration, err := d.Duration()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Info = c.FrameInfo()
+	res.Duration = duration
+	res.Markers = d.Markers
+	res.Instrument = d.Instrument
+	return res
+}
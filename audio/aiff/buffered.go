@@ -0,0 +1,58 @@
+package aiff
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// bufferedClip reads its underlying clip through a bufio.Reader.
+type bufferedClip struct {
+	audio.Clip
+	br *bufio.Reader
+	// pos is the read position as seen by the caller, the underlying
+	// clip being ahead by the buffered amount.
+	pos int64
+}
+
+// Buffered wraps c so its data is read in blocks of size bytes, reducing the
+// number of reads issued to a file backed clip when consuming it in small
+// pieces. Seeking discards the buffered data.
+func Buffered(c audio.Clip, size int) audio.Clip {
+	pos, _ := c.Seek(0, io.SeekCurrent)
+	return &bufferedClip{Clip: c, br: bufio.NewReaderSize(c, size), pos: pos}
+}
+
+func (b *bufferedClip) Read(p []byte) (n int, err error) {
+	n, err = b.br.Read(p)
+	b.pos += int64(n)
+	return n, err
+}
+
+func (b *bufferedClip) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekCurrent:
+		if offset == 0 {
+			return b.pos, nil
+		}
+		abs = b.pos + offset
+	case io.SeekEnd:
+		var err error
+		if abs, err = b.Clip.Seek(offset, io.SeekEnd); err != nil {
+			return 0, err
+		}
+	case io.SeekStart:
+		abs = offset
+	default:
+		return b.Clip.Seek(offset, whence)
+	}
+	abs, err := b.Clip.Seek(abs, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	b.br.Reset(b.Clip)
+	b.pos = abs
+	return abs, nil
+}
@@ -0,0 +1,89 @@
+package aiff_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+)
+
+// readCounter counts the reads issued to a ReadSeeker.
+type readCounter struct {
+	io.ReadSeeker
+	reads int
+}
+
+func (r *readCounter) Read(p []byte) (int, error) {
+	r.reads++
+	return r.ReadSeeker.Read(p)
+}
+
+func TestBufferedSeek(t *testing.T) {
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	c := aiff.Buffered(aiff.RawClip(bytes.NewReader(data), info, int64(len(data))), 16)
+
+	p := make([]byte, 2)
+	for _, step := range []struct {
+		offset int64
+		whence int
+		pos    int64
+	}{
+		{0, io.SeekCurrent, 0},
+		// the buffer was filled past the position, reads must not
+		// return its stale content
+		{10, io.SeekStart, 10},
+		{-4, io.SeekCurrent, 8},
+		{-2, io.SeekEnd, 62},
+	} {
+		pos, err := c.Seek(step.offset, step.whence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != step.pos {
+			t.Fatalf("expected position %d, got %d", step.pos, pos)
+		}
+		if _, err := io.ReadFull(c, p); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p, data[pos:pos+2]) {
+			t.Fatalf("at %d: expected %v, got %v", pos, data[pos:pos+2], p)
+		}
+		if cur, _ := c.Seek(0, io.SeekCurrent); cur != pos+2 {
+			t.Fatalf("expected position %d after reading, got %d", pos+2, cur)
+		}
+	}
+}
+
+func BenchmarkBuffered(b *testing.B) {
+	data := make([]byte, 1<<16)
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	for _, tt := range []struct {
+		name     string
+		buffered bool
+	}{{"unbuffered", false}, {"buffered", true}} {
+		b.Run(tt.name, func(b *testing.B) {
+			r := &readCounter{ReadSeeker: bytes.NewReader(data)}
+			var c audio.Clip = aiff.RawClip(r, info, int64(len(data)))
+			if tt.buffered {
+				c = aiff.Buffered(c, 4096)
+			}
+			frame := make([]byte, info.BytesPerFrame())
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				c.Seek(0, io.SeekStart)
+				for {
+					if _, err := io.ReadFull(c, frame); err != nil {
+						break
+					}
+				}
+			}
+			b.ReportMetric(float64(r.reads)/float64(b.N), "reads/op")
+		})
+	}
+}
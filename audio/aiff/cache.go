@@ -0,0 +1,132 @@
+package aiff
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// ClipCache keeps decoded clips in memory, keyed by the Fingerprint of their
+// content and their frame information, evicting the least recently used
+// ones once the total size of the cached sound data exceeds its capacity.
+// It is safe for concurrent use.
+type ClipCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	// lru holds the entries, the most recently used first
+	lru   *list.List
+	items map[[32]byte]*list.Element
+}
+
+// cacheEntry is a cached clip.
+type cacheEntry struct {
+	key  [32]byte
+	data []byte
+	info audio.FrameInfo
+}
+
+// NewClipCache returns a cache holding up to maxBytes of sound data.
+func NewClipCache(maxBytes int64) *ClipCache {
+	return &ClipCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    make(map[[32]byte]*list.Element),
+	}
+}
+
+// Put copies the sound data of c in the cache and returns its key.
+// Clips larger than the cache capacity aren't stored.
+// The read position of c is restored afterwards.
+func (cc *ClipCache) Put(c audio.Clip) ([32]byte, error) {
+	key, err := cacheKey(c)
+	if err != nil {
+		return key, err
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if e, ok := cc.items[key]; ok {
+		cc.lru.MoveToFront(e)
+		return key, nil
+	}
+	if c.Size() > cc.maxBytes {
+		return key, nil
+	}
+
+	pos, err := c.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return key, err
+	}
+	defer c.Seek(pos, io.SeekStart)
+	if _, err := c.Seek(0, io.SeekStart); err != nil {
+		return key, err
+	}
+	data := make([]byte, c.Size())
+	n, err := io.ReadFull(c, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return key, err
+	}
+
+	cc.items[key] = cc.lru.PushFront(&cacheEntry{key: key, data: data[:n], info: c.FrameInfo()})
+	cc.size += int64(n)
+	for cc.size > cc.maxBytes {
+		cc.remove(cc.lru.Back())
+	}
+	return key, nil
+}
+
+// cacheKey returns the key of c: the Fingerprint of its samples hashed with
+// its frame information, the same samples at another sample rate or channel
+// layout being a different clip.
+func cacheKey(c audio.Clip) ([32]byte, error) {
+	sum, err := Fingerprint(c)
+	if err != nil {
+		return sum, err
+	}
+	info := c.FrameInfo()
+	b := make([]byte, len(sum), len(sum)+16)
+	copy(b, sum[:])
+	b = binary.BigEndian.AppendUint32(b, uint32(info.Channels))
+	b = binary.BigEndian.AppendUint32(b, uint32(info.BitDepth))
+	b = binary.BigEndian.AppendUint64(b, uint64(info.SampleRate))
+	return sha256.Sum256(b), nil
+}
+
+// Get returns a clip reading the cached data matching key, with its own
+// read position, and whether the key was found.
+func (cc *ClipCache) Get(key [32]byte) (audio.Clip, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	e, ok := cc.items[key]
+	if !ok {
+		return nil, false
+	}
+	cc.lru.MoveToFront(e)
+	entry := e.Value.(*cacheEntry)
+	return &Clip{
+		r:          bytes.NewReader(entry.data),
+		size:       int64(len(entry.data)),
+		channels:   entry.info.Channels,
+		bitDepth:   entry.info.BitDepth,
+		sampleRate: entry.info.SampleRate,
+	}, true
+}
+
+// Size returns the total size of the cached sound data.
+func (cc *ClipCache) Size() int64 {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.size
+}
+
+// remove evicts the passed entry.
+func (cc *ClipCache) remove(e *list.Element) {
+	entry := cc.lru.Remove(e).(*cacheEntry)
+	delete(cc.items, entry.key)
+	cc.size -= int64(len(entry.data))
+}
@@ -0,0 +1,36 @@
+package aiff
+
+import (
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+)
+
+func TestClipCacheKeyIncludesFormat(t *testing.T) {
+	mono := newMemClip([][]int{{1}, {2}, {3}, {4}}, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 44100})
+	stereo := newMemClip([][]int{{1, 2}, {3, 4}}, audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 48000})
+	cc := NewClipCache(1 << 20)
+	monoKey, err := cc.Put(mono)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stereoKey, err := cc.Put(stereo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monoKey == stereoKey {
+		t.Fatal("clips with the same samples but different formats share a key")
+	}
+	for key, expected := range map[[32]byte]audio.FrameInfo{monoKey: mono.FrameInfo(), stereoKey: stereo.FrameInfo()} {
+		c, ok := cc.Get(key)
+		if !ok {
+			t.Fatalf("%s clip not cached", expected)
+		}
+		if c.FrameInfo() != expected {
+			t.Fatalf("expected a %s clip, got %s", expected, c.FrameInfo())
+		}
+	}
+	if cc.Size() != 16 {
+		t.Fatalf("expected 16 cached bytes, got %d", cc.Size())
+	}
+}
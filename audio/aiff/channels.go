@@ -0,0 +1,113 @@
+package aiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// channelClip exposes a subset of the channels of its source clip.
+type channelClip struct {
+	src audio.Clip
+	// channels are the indexes of the source channels, in output order
+	channels []int
+	// pos is the read position in the subset data
+	pos int64
+}
+
+// DecodeChannels decodes the AIFF data read from r and returns a clip only
+// exposing the passed channels, in the passed order. The other channels are
+// skipped when reading instead of being converted and dropped.
+func DecodeChannels(r io.ReadSeeker, channels []int) (audio.Clip, error) {
+	c, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return nil, errors.New("no channel selected")
+	}
+	for _, ch := range channels {
+		if ch < 0 || ch >= c.Channels() {
+			return nil, fmt.Errorf("channel %d out of range, the clip has %d channel(s)", ch, c.Channels())
+		}
+	}
+	return &channelClip{src: c, channels: append([]int(nil), channels...)}, nil
+}
+
+func (c *channelClip) Read(p []byte) (n int, err error) {
+	if c.pos >= c.Size() {
+		return 0, io.EOF
+	}
+	sampleSize := int64(sampleBytes(c.src.BitDepth()))
+	srcFrameSize := sampleSize * int64(c.src.Channels())
+	frameSize := sampleSize * int64(len(c.channels))
+	if remaining := c.Size() - c.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	// read the source frames covering the requested range
+	first := c.pos / frameSize
+	last := (c.pos + int64(len(p)) + frameSize - 1) / frameSize
+	if _, err := c.src.Seek(first*srcFrameSize, io.SeekStart); err != nil {
+		return 0, err
+	}
+	src := make([]byte, (last-first)*srcFrameSize)
+	read, err := io.ReadFull(c.src, src)
+	frames := int64(read) / srcFrameSize
+	subset := make([]byte, 0, frames*frameSize)
+	for i := int64(0); i < frames; i++ {
+		frame := src[i*srcFrameSize:]
+		for _, ch := range c.channels {
+			offset := int64(ch) * sampleSize
+			subset = append(subset, frame[offset:offset+sampleSize]...)
+		}
+	}
+	// the read can start in the middle of a frame
+	skip := c.pos - first*frameSize
+	if skip > int64(len(subset)) {
+		skip = int64(len(subset))
+	}
+	n = copy(p, subset[skip:])
+	c.pos += int64(n)
+	if (err == io.ErrUnexpectedEOF || err == io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (c *channelClip) Seek(offset int64, whence int) (int64, error) {
+	abs, err := seekPos(c.pos, c.Size(), offset, whence, "aiff.DecodeChannels")
+	if err != nil {
+		return 0, err
+	}
+	c.pos = abs
+	return abs, nil
+}
+
+func (c *channelClip) FrameInfo() audio.FrameInfo {
+	info := c.src.FrameInfo()
+	info.Channels = len(c.channels)
+	return info
+}
+
+func (c *channelClip) Channels() int {
+	return len(c.channels)
+}
+
+func (c *channelClip) SampleRate() int64 {
+	return c.src.SampleRate()
+}
+
+func (c *channelClip) BitDepth() int {
+	return c.src.BitDepth()
+}
+
+func (c *channelClip) Size() int64 {
+	srcFrameSize := int64(c.src.FrameInfo().BytesPerFrame())
+	if srcFrameSize == 0 {
+		return 0
+	}
+	return c.src.Size() / srcFrameSize * int64(c.FrameInfo().BytesPerFrame())
+}
@@ -89,3 +89,47 @@ func (ch *Chunk) IsFullyRead() bool {
 	}
 	return ch.Size <= ch.Pos
 }
+
+// WriteChunk writes a chunk with the passed ID and data to w.
+// A pad byte is added after the data if its length is odd, as required by the
+// spec. The pad byte isn't included in the chunk size but is counted in the
+// returned number of bytes written.
+func WriteChunk(w io.Writer, id [4]byte, data []byte) (int, error) {
+	header := make([]byte, 8)
+	copy(header, id[:])
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	n, err := w.Write(header)
+	if err != nil {
+		return n, err
+	}
+	m, err := w.Write(data)
+	n += m
+	if err != nil {
+		return n, err
+	}
+	if len(data)%2 == 1 {
+		m, err = w.Write([]byte{0})
+		n += m
+	}
+	return n, err
+}
+
+// readPString reads a pascal style string, a count byte followed by the text,
+// padded to an even total length. It returns the text and the number of bytes
+// consumed, pad byte included.
+func readPString(r io.Reader) (string, int, error) {
+	var count [1]byte
+	if _, err := io.ReadFull(r, count[:]); err != nil {
+		return "", 0, err
+	}
+	size := 1 + int(count[0])
+	if size%2 == 1 {
+		size++
+	}
+	b := make([]byte, size-1)
+	n, err := io.ReadFull(r, b)
+	if err != nil {
+		return "", 1 + n, err
+	}
+	return string(b[:count[0]]), size, nil
+}
@@ -1,25 +1,307 @@
 package aiff
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/mattetti/exp/audio"
 )
 
+// Clip represents the PCM data found in the SSND chunk of an AIFF stream.
+// Samples are stored as big endian signed integers, including 8-bit samples
+// which, contrary to WAV, are signed.
 type Clip struct {
-	r          io.ReadSeeker
+	// Offset and BlockSize are the SSND chunk fields used to align block
+	// based compressed data. Offset bytes of padding precede the first
+	// sample frame, BlockSize is the size of the blocks the data is
+	// aligned to (0 when not block aligned).
+	Offset    uint32
+	BlockSize uint32
+
+	r io.ReadSeeker
+	// start is the position of the first sample byte in r.
+	start int64
+	// pos is the read position relative to start.
+	pos        int64
 	size       int64
 	channels   int
 	bitDepth   int
 	sampleRate int64
+	// readBlock is the size in bytes of the buffer used when
+	// reading the clip in blocks, 0 means defaultBlockSize.
+	readBlock int
+	// format is the encoding of the source samples, derived from the
+	// bit depth when not set.
+	format audio.SampleFormat
+	// outDepth is the bit depth of the clips produced by the transforms,
+	// 0 keeping the bit depth of the clip.
+	outDepth int
+}
+
+// defaultBlockSize is the read buffer size used unless changed with
+// SetBlockSize.
+const defaultBlockSize = 4096
+
+// RawClip presents headerless PCM data, such as .pcm or .raw dumps, as a
+// clip. The data is expected to start at the current position of r and to
+// use the AIFF sample layout: big endian signed samples.
+func RawClip(r io.ReadSeeker, info audio.FrameInfo, dataSize int64) audio.Clip {
+	start, _ := r.Seek(0, io.SeekCurrent)
+	return &Clip{
+		r:          r,
+		start:      start,
+		size:       dataSize,
+		channels:   info.Channels,
+		bitDepth:   info.BitDepth,
+		sampleRate: info.SampleRate,
+	}
 }
 
+// Read reads up to len(p) bytes of PCM data and returns io.EOF once
+// the end of the sound data is reached. Little endian samples, such as the
+// ones of sowt AIFC files, are byte swapped so the data is always big endian.
 func (c *Clip) Read(p []byte) (n int, err error) {
-	return
+	if c.pos >= c.size {
+		return 0, io.EOF
+	}
+	if c.littleEndian() {
+		return c.readSwapped(p)
+	}
+	if _, err := c.r.Seek(c.start+c.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if remaining := c.size - c.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err = c.r.Read(p)
+	c.pos += int64(n)
+	return n, err
+}
+
+// readSwapped reads little endian samples, reading the whole samples
+// covering p so they can be swapped even when p starts or ends in the
+// middle of a sample.
+func (c *Clip) readSwapped(p []byte) (n int, err error) {
+	if remaining := c.size - c.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	sampleSize := int64(sampleBytes(c.bitDepth))
+	first := c.pos / sampleSize * sampleSize
+	last := (c.pos + int64(len(p)) + sampleSize - 1) / sampleSize * sampleSize
+	if last > c.size {
+		last = c.size
+	}
+	if _, err := c.r.Seek(c.start+first, io.SeekStart); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, last-first)
+	read, err := io.ReadFull(c.r, buf)
+	buf = buf[:read]
+	for i := int64(0); i+sampleSize <= int64(len(buf)); i += sampleSize {
+		b := buf[i : i+sampleSize]
+		for l, r := 0, len(b)-1; l < r; l, r = l+1, r-1 {
+			b[l], b[r] = b[r], b[l]
+		}
+	}
+	skip := c.pos - first
+	if skip > int64(len(buf)) {
+		skip = int64(len(buf))
+	}
+	n = copy(p, buf[skip:])
+	c.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+		if n == 0 {
+			err = io.EOF
+		}
+	}
+	return n, err
 }
 
+// littleEndian reports whether the source samples are little endian.
+func (c *Clip) littleEndian() bool {
+	switch c.format {
+	case audio.PCMS16LE, audio.PCMS24LE, audio.PCMS32LE:
+		return true
+	}
+	return false
+}
+
+// Seek sets the offset for the next Read, relative to the beginning of the
+// sound data. io.SeekEnd is relative to the end of the sound data, not to
+// the end of the file which can contain other chunks after the SSND chunk.
 func (c *Clip) Seek(offset int64, whence int) (int64, error) {
-	return 0, nil
+	abs, err := seekPos(c.pos, c.size, offset, whence, "aiff.Clip.Seek")
+	if err != nil {
+		return 0, err
+	}
+	c.pos = abs
+	return abs, nil
+}
+
+// seekPos returns the position resulting from seeking by offset relative to
+// whence in a clip of the passed size, pos being the current position. name
+// prefixes the returned errors.
+func seekPos(pos, size, offset int64, whence int, name string) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = pos + offset
+	case io.SeekEnd:
+		abs = size + offset
+	default:
+		return 0, errors.New(name + ": invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New(name + ": negative position")
+	}
+	return abs, nil
+}
+
+// SetBlockSize sets the size in bytes of the buffer used by WriteTo and by
+// the functions processing the whole clip. It is unrelated to the SSND
+// BlockSize field. Smaller blocks use less memory at the cost of more reads.
+// The size has to be positive.
+func (c *Clip) SetBlockSize(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("aiff.Clip.SetBlockSize: invalid block size %d", n)
+	}
+	c.readBlock = n
+	return nil
+}
+
+// SetOutputBitDepth sets the bit depth of the clips produced by the
+// transforms processing c, such as Gain or Resample, the samples being
+// rescaled to it. Like FrameInfo.Validate, the depth has to be between 1
+// and 32. A depth of 0 restores the default, keeping the bit depth of c so
+// processing doesn't change its precision.
+func (c *Clip) SetOutputBitDepth(bitDepth int) error {
+	if bitDepth < 0 || bitDepth > 32 {
+		return fmt.Errorf("%s - unsupported bit depth %d", ErrFmtNotSupported, bitDepth)
+	}
+	c.outDepth = bitDepth
+	return nil
+}
+
+// blockBytes returns the block size to use when reading the clip.
+func (c *Clip) blockBytes() int {
+	if c.readBlock <= 0 {
+		return defaultBlockSize
+	}
+	return c.readBlock
+}
+
+// WriteTo writes the PCM data from the current position to w, reading it
+// in blocks. It implements io.WriterTo.
+func (c *Clip) WriteTo(w io.Writer) (n int64, err error) {
+	return c.WriteToWithProgress(w, nil)
+}
+
+// WriteToWithProgress is like WriteTo but calls cb after each block with the
+// number of frames written so far, counted from the start of the clip, for
+// instance to move the playhead of a player.
+func (c *Clip) WriteToWithProgress(w io.Writer, cb func(frame int64)) (n int64, err error) {
+	frameSize := int64(c.FrameInfo().BytesPerFrame())
+	buf := make([]byte, c.blockBytes())
+	for {
+		nr, er := c.Read(buf)
+		if nr > 0 {
+			nw, ew := w.Write(buf[:nr])
+			n += int64(nw)
+			if ew != nil {
+				return n, ew
+			}
+			if nw != nr {
+				return n, io.ErrShortWrite
+			}
+			if cb != nil && frameSize > 0 {
+				cb(c.pos / frameSize)
+			}
+		}
+		if er == io.EOF {
+			return n, nil
+		}
+		if er != nil {
+			return n, er
+		}
+	}
+}
+
+// ReadFrames decodes up to len(frames) sample frames from the clip.
+// Each frame holds one signed sample per channel. ReadFrames returns the
+// number of frames read and io.EOF once no more frames are available.
+// Floating point data can't be read as frames and returns
+// ErrFmtNotSupported.
+func (c *Clip) ReadFrames(frames [][]int) (n int, err error) {
+	switch f := c.Format(); f {
+	case audio.Float32BE, audio.Float64BE:
+		return 0, fmt.Errorf("%s - can't read %s samples as integers", ErrFmtNotSupported, f)
+	}
+	return readFrames(c, frames, c.channels, c.bitDepth)
+}
+
+// ReadFloat32 decodes interleaved samples normalized to [-1, 1) into buf, as
+// expected by WebAudio, reading whole frames only. It returns the number of
+// samples decoded and io.EOF once no more frames are available.
+func (c *Clip) ReadFloat32(buf []float32) (n int, err error) {
+	if c.channels < 1 {
+		return 0, ErrFmtNotSupported
+	}
+	frames := make([][]int, len(buf)/c.channels)
+	read, err := c.ReadFrames(frames)
+	fullScale := float32(maxSample(c.bitDepth) + 1)
+	for _, frame := range frames[:read] {
+		for _, v := range frame {
+			buf[n] = float32(v) / fullScale
+			n++
+		}
+	}
+	return n, err
+}
+
+// readFrames decodes up to len(frames) big endian PCM frames read from r.
+func readFrames(r io.Reader, frames [][]int, channels, bitDepth int) (n int, err error) {
+	if bitDepth < 1 || bitDepth > 32 || channels < 1 {
+		return 0, ErrFmtNotSupported
+	}
+	sampleSize := sampleBytes(bitDepth)
+	frameSize := sampleSize * channels
+	buf := make([]byte, frameSize*len(frames))
+	read, err := io.ReadFull(r, buf)
+	n = read / frameSize
+	for i := 0; i < n; i++ {
+		if len(frames[i]) != channels {
+			frames[i] = make([]int, channels)
+		}
+		for j := range frames[i] {
+			offset := (i*channels + j) * sampleSize
+			frames[i][j] = decodeSample(buf[offset:offset+sampleSize], bitDepth)
+		}
+	}
+	if err == io.ErrUnexpectedEOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadFrameAt returns the samples of the passed sample frame, one per
+// channel. The read position of the clip isn't changed, making it suitable
+// for sampling sparse frames.
+func (c *Clip) ReadFrameAt(frame int64) ([]int, error) {
+	s, err := c.section(frame, frame+1)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([][]int, 1)
+	if _, err := s.ReadFrames(frames); err != nil {
+		return nil, err
+	}
+	return frames[0], nil
 }
 
 func (c *Clip) FrameInfo() audio.FrameInfo {
@@ -30,6 +312,145 @@ func (c *Clip) FrameInfo() audio.FrameInfo {
 	}
 }
 
+// Format returns the encoding of the samples in the source file.
+func (c *Clip) Format() audio.SampleFormat {
+	if c.format != audio.UnknownFormat {
+		return c.format
+	}
+	return pcmFormat(c.bitDepth)
+}
+
+// pcmFormat returns the big endian PCM format storing samples of the passed
+// bit depth.
+func pcmFormat(bitDepth int) audio.SampleFormat {
+	switch sampleBytes(bitDepth) {
+	case 1:
+		return audio.PCMS8
+	case 2:
+		return audio.PCMS16BE
+	case 3:
+		return audio.PCMS24BE
+	case 4:
+		return audio.PCMS32BE
+	}
+	return audio.UnknownFormat
+}
+
+// pcmLEFormat returns the little endian PCM format storing samples of the
+// passed bit depth.
+func pcmLEFormat(bitDepth int) audio.SampleFormat {
+	switch sampleBytes(bitDepth) {
+	case 1:
+		return audio.PCMS8
+	case 2:
+		return audio.PCMS16LE
+	case 3:
+		return audio.PCMS24LE
+	case 4:
+		return audio.PCMS32LE
+	}
+	return audio.UnknownFormat
+}
+
+// Channels returns the number of audio channels of the clip.
+func (c *Clip) Channels() int {
+	return c.channels
+}
+
+// SampleRate returns the number of samples played per second.
+func (c *Clip) SampleRate() int64 {
+	return c.sampleRate
+}
+
+// BitDepth returns the number of bits used to represent a single sample.
+func (c *Clip) BitDepth() int {
+	return c.bitDepth
+}
+
 func (c *Clip) Size() int64 {
 	return c.size
 }
+
+// NumFrames returns the number of sample frames in the clip, computed from
+// the size of the sound data rather than from the COMM chunk.
+func (c *Clip) NumFrames() int64 {
+	frameSize := c.FrameInfo().BytesPerFrame()
+	if frameSize == 0 {
+		return 0
+	}
+	return c.size / int64(frameSize)
+}
+
+// section returns a clip sharing the reader of c and covering the frames
+// between startFrame (included) and endFrame (excluded).
+func (c *Clip) section(startFrame, endFrame int64) (*Clip, error) {
+	frameSize := int64(c.FrameInfo().BytesPerFrame())
+	if startFrame < 0 || startFrame > endFrame || endFrame*frameSize > c.size {
+		return nil, fmt.Errorf("invalid frame range %d-%d", startFrame, endFrame)
+	}
+	return &Clip{
+		Offset:     c.Offset,
+		BlockSize:  c.BlockSize,
+		r:          c.r,
+		start:      c.start + startFrame*frameSize,
+		size:       (endFrame - startFrame) * frameSize,
+		channels:   c.channels,
+		bitDepth:   c.bitDepth,
+		sampleRate: c.sampleRate,
+		readBlock:  c.readBlock,
+		format:     c.format,
+		outDepth:   c.outDepth,
+	}, nil
+}
+
+// Copy reads the whole PCM data of c into memory and returns an independent,
+// seekable clip with the same frame information.
+// The read position of c is left untouched.
+func Copy(c audio.Clip) (audio.Clip, error) {
+	pos, err := c.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Seek(pos, io.SeekStart)
+	if _, err := c.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, c.Size())
+	n, err := io.ReadFull(c, data)
+	// keep what we got out of a truncated clip
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	info := c.FrameInfo()
+	return &Clip{
+		r:          bytes.NewReader(data[:n]),
+		size:       int64(n),
+		channels:   info.Channels,
+		bitDepth:   info.BitDepth,
+		sampleRate: info.SampleRate,
+	}, nil
+}
+
+// sampleBytes returns the number of bytes used to store a sample.
+// Samples are padded to the next byte boundary.
+func sampleBytes(bitDepth int) int {
+	return (bitDepth + 7) / 8
+}
+
+// decodeSample converts a big endian, left-justified sample into a signed int.
+// 8-bit AIFF samples are signed, not offset like their WAV counterparts.
+func decodeSample(b []byte, bitDepth int) int {
+	var v int
+	switch len(b) {
+	case 1:
+		v = audio.Int8Sample(b[0])
+	case 2:
+		v = int(int16(binary.BigEndian.Uint16(b)))
+	case 3:
+		v = int(int32(uint32(b[0])<<24|uint32(b[1])<<16|uint32(b[2])<<8) >> 8)
+	case 4:
+		v = int(int32(binary.BigEndian.Uint32(b)))
+	}
+	return v >> uint(len(b)*8-bitDepth)
+}
@@ -0,0 +1,710 @@
+package aiff_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+	"github.com/mattetti/exp/audio/aiff/testutil"
+)
+
+// encode returns the AIFF file encoding frames, as an AIFC file using
+// encoding when set.
+func encode(t testing.TB, info audio.FrameInfo, frames [][]int, encoding string) []byte {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "*.aif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	e := aiff.NewEncoder(f, info)
+	if encoding != "" {
+		var fourCC [4]byte
+		copy(fourCC[:], encoding)
+		if err := e.SetEncoding(fourCC, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Write(frames); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	for _, encoding := range []string{"", "NONE", "sowt"} {
+		for _, depth := range []int{8, 16, 24, 32} {
+			t.Run(fmt.Sprintf("%q %d-bit", encoding, depth), func(t *testing.T) {
+				info := audio.FrameInfo{Channels: 2, BitDepth: depth, SampleRate: 44100}
+				frames := testutil.Ramp(info, 300)
+				frames[0] = []int{1, -1}
+				c, err := aiff.Decode(bytes.NewReader(encode(t, info, frames, encoding)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _, err := aiff.ReadAll(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, frames) {
+					t.Fatalf("expected %v, got %v", frames[:4], got[:4])
+				}
+			})
+		}
+	}
+}
+
+func TestSowtPartialReads(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 24, SampleRate: 8000}
+	frames := [][]int{{1}, {-2}, {0x123456}}
+	c, err := aiff.Decode(bytes.NewReader(encode(t, info, frames, "sowt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// single byte reads cross the sample boundaries
+	var data []byte
+	b := make([]byte, 1)
+	for {
+		n, err := c.Read(b)
+		data = append(data, b[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []byte{0, 0, 1, 0xff, 0xff, 0xfe, 0x12, 0x34, 0x56}
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("expected %x, got %x", expected, data)
+	}
+}
+
+func TestSowtTestutilFile(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 44100}
+	f, err := os.CreateTemp(t.TempDir(), "*.aif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := testutil.WriteTestAIFF(f, info, testutil.TestOpts{Samples: [][]int{{1}, {-1}}, Sowt: true}); err != nil {
+		t.Fatal(err)
+	}
+	f.Seek(0, io.SeekStart)
+	c, err := aiff.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int{{1}, {-1}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestPadToSeek(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 4)
+	c, err := aiff.NewDecoder(bytes.NewReader(encode(t, info, frames, ""))).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded, err := aiff.PadTo(c, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := padded.Seek(-8, io.SeekEnd); err != nil || pos != 12 {
+		t.Fatalf("expected position 12, got %d (%v)", pos, err)
+	}
+	tail, err := io.ReadAll(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tail, make([]byte, 8)) {
+		t.Fatalf("expected 8 zero bytes, got %v", tail)
+	}
+	if _, err := padded.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("expected an error seeking before the start")
+	}
+	if _, err := padded.Seek(0, 3); err == nil {
+		t.Fatal("expected an error for an invalid whence")
+	}
+}
+
+func TestPadToShortSource(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	// the source declares 8 bytes but only holds 4
+	src := aiff.RawClip(bytes.NewReader([]byte{0, 1, 0, 2}), info, 8)
+	padded, err := aiff.PadTo(src, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(padded); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestSetBlockSize(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 44100}
+	frames := testutil.Ramp(info, 1000)
+	b := encode(t, info, frames, "")
+	c, err := aiff.NewDecoder(bytes.NewReader(b)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip := c.(*aiff.Clip)
+	for _, n := range []int{0, -1} {
+		if err := clip.SetBlockSize(n); err == nil {
+			t.Fatalf("expected an error for a block size of %d", n)
+		}
+	}
+	expected := &bytes.Buffer{}
+	if _, err := clip.WriteTo(expected); err != nil {
+		t.Fatal(err)
+	}
+
+	// blocks smaller than a frame are read a frame at a time
+	for _, n := range []int{1, 5, 6, 7, 4096} {
+		if err := clip.SetBlockSize(n); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := clip.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		got := &bytes.Buffer{}
+		if _, err := clip.WriteTo(got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), expected.Bytes()) {
+			t.Fatalf("block size %d: WriteTo output differs", n)
+		}
+		all, _, err := aiff.ReadAll(clip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(all, frames) {
+			t.Fatalf("block size %d: ReadAll frames differ", n)
+		}
+	}
+}
+
+func BenchmarkWriteToBlockSize(b *testing.B) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	data := encode(b, info, testutil.Ramp(info, 44100), "")
+	for _, n := range []int{256, 4096, 65536} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			c, err := aiff.NewDecoder(bytes.NewReader(data)).Decode()
+			if err != nil {
+				b.Fatal(err)
+			}
+			clip := c.(*aiff.Clip)
+			if err := clip.SetBlockSize(n); err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(clip.Size())
+			for i := 0; i < b.N; i++ {
+				clip.Seek(0, io.SeekStart)
+				if _, err := clip.WriteTo(io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeSigned8Bit(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}
+	frames := [][]int{{-128}, {-1}, {0}, {1}, {127}}
+	b := encode(t, info, frames, "")
+	// AIFF stores 8-bit samples as two's complement, not offset by 128
+	if data := b[len(b)-6 : len(b)-1]; !bytes.Equal(data, []byte{0x80, 0xff, 0x00, 0x01, 0x7f}) {
+		t.Fatalf("unexpected 8-bit sound data %x", data)
+	}
+	c, err := aiff.NewDecoder(bytes.NewReader(b)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected %v, got %v", frames, got)
+	}
+}
+
+func TestClipAccessors(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 48000}
+	b := encode(t, info, testutil.Ramp(info, 10), "")
+	c, err := aiff.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded, err := aiff.PadTo(c, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, err := aiff.DecodeChannels(bytes.NewReader(b), []int{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ulaw, err := aiff.Decode(bytes.NewReader(ulawFile([]byte{0xff, 0x80})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]audio.Clip{"clip": c, "padded": padded, "channels": left, "ulaw": ulaw} {
+		fi := c.FrameInfo()
+		if c.Channels() != fi.Channels || c.SampleRate() != fi.SampleRate || c.BitDepth() != fi.BitDepth {
+			t.Errorf("%s: accessors %d/%d/%d don't match %s", name, c.Channels(), c.BitDepth(), c.SampleRate(), fi)
+		}
+	}
+	if c.FrameInfo() != info || left.Channels() != 1 || ulaw.BitDepth() != 16 {
+		t.Fatalf("unexpected frame info %s, %s and %s", c.FrameInfo(), left.FrameInfo(), ulaw.FrameInfo())
+	}
+}
+
+func TestCopy(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 300)
+	path := t.TempDir() + "/src.aif"
+	if err := os.WriteFile(path, encode(t, info, frames, ""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	c, err := aiff.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Seek(40, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := aiff.Copy(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := c.Seek(0, io.SeekCurrent); pos != 40 {
+		t.Fatalf("expected Copy to keep the position of the source, got %d", pos)
+	}
+	if cp.FrameInfo() != info || cp.Size() != c.Size() {
+		t.Fatalf("expected a copy of %s, %d bytes, got %s, %d bytes", info, c.Size(), cp.FrameInfo(), cp.Size())
+	}
+	got, _, err := aiff.ReadAll(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, frames) {
+		t.Fatal("the copied samples differ from the source")
+	}
+
+	// the copy is independent from the source, and still usable once the
+	// file is closed
+	if _, err := cp.Seek(8, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if pos, _ := c.Seek(0, io.SeekCurrent); pos != 40 {
+		t.Fatalf("seeking the copy moved the source to %d", pos)
+	}
+	p := make([]byte, 4)
+	if _, err := io.ReadFull(cp, p); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{byte(uint16(frames[2][0]) >> 8), byte(frames[2][0]), byte(uint16(frames[2][1]) >> 8), byte(frames[2][1])}
+	if !bytes.Equal(p, expected) {
+		t.Fatalf("expected frame 2 %x, got %x", expected, p)
+	}
+}
+
+func TestRawClip(t *testing.T) {
+	// 16-bit stereo frames: (1, -1), (256, -32768)
+	data := []byte{0x00, 0x01, 0xff, 0xff, 0x01, 0x00, 0x80, 0x00}
+	r := bytes.NewReader(append([]byte("junk"), data...))
+	r.Seek(4, io.SeekStart)
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 22050}
+	c := aiff.RawClip(r, info, int64(len(data)))
+	if c.FrameInfo() != info || c.Size() != int64(len(data)) {
+		t.Fatalf("expected %s and %d bytes, got %s and %d bytes", info, len(data), c.FrameInfo(), c.Size())
+	}
+	frames, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int{{1, -1}, {256, -32768}}; !reflect.DeepEqual(frames, expected) {
+		t.Fatalf("expected %v, got %v", expected, frames)
+	}
+}
+
+func TestNumFrames(t *testing.T) {
+	for _, info := range []audio.FrameInfo{
+		{Channels: 1, BitDepth: 8, SampleRate: 8000},
+		{Channels: 2, BitDepth: 24, SampleRate: 44100},
+		{Channels: 6, BitDepth: 12, SampleRate: 48000},
+	} {
+		c, err := aiff.Decode(bytes.NewReader(encode(t, info, testutil.Ramp(info, 123), "")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := c.(*aiff.Clip).NumFrames(); n != 123 {
+			t.Fatalf("%s: expected 123 frames, got %d", info, n)
+		}
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 1<<19)
+	path := t.TempDir() + "/large.aif"
+	if err := os.WriteFile(path, encode(t, info, frames, ""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, closeFn, err := aiff.DecodeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip := c.(*aiff.Clip)
+	total := int64(len(frames))
+	if n := clip.NumFrames(); n != total {
+		t.Fatalf("expected %d frames, got %d", total, n)
+	}
+	for _, i := range []int64{total - 1, 0, 123457, total / 2, 9} {
+		frame, err := clip.ReadFrameAt(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(frame, frames[i]) {
+			t.Fatalf("frame %d: expected %v, got %v", i, frames[i], frame)
+		}
+	}
+	if err := closeFn(); err != nil {
+		t.Fatal(err)
+	}
+	if err := closeFn(); err == nil {
+		t.Fatal("expected an error closing the file twice")
+	}
+}
+
+func TestReadFrameAt(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 44100}
+	frames := testutil.Ramp(info, 101)
+	c, err := aiff.Decode(bytes.NewReader(encode(t, info, frames, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip := c.(*aiff.Clip)
+	if _, err := clip.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int64{0, 50, 100} {
+		frame, err := clip.ReadFrameAt(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(frame, frames[i]) {
+			t.Fatalf("frame %d: expected %v, got %v", i, frames[i], frame)
+		}
+	}
+	if pos, _ := clip.Seek(0, io.SeekCurrent); pos != 6 {
+		t.Fatalf("expected the read position to stay at 6, got %d", pos)
+	}
+	if _, err := clip.ReadFrameAt(101); err == nil {
+		t.Fatal("expected an error reading past the last frame")
+	}
+}
+
+func TestClipFormat(t *testing.T) {
+	ulaw := ulawFile([]byte{0xff, 0x7f})
+	alaw := bytes.Replace(ulaw, []byte("ulaw"), []byte("alaw"), 1)
+	tests := []struct {
+		name     string
+		data     []byte
+		expected audio.SampleFormat
+	}{
+		{"8-bit", encode(t, audio.FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}, [][]int{{1}}, ""), audio.PCMS8},
+		{"16-bit", encode(t, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}, [][]int{{1}}, ""), audio.PCMS16BE},
+		{"20-bit", encode(t, audio.FrameInfo{Channels: 1, BitDepth: 20, SampleRate: 8000}, [][]int{{1}}, ""), audio.PCMS24BE},
+		{"32-bit", encode(t, audio.FrameInfo{Channels: 1, BitDepth: 32, SampleRate: 8000}, [][]int{{1}}, ""), audio.PCMS32BE},
+		{"NONE", encode(t, audio.FrameInfo{Channels: 1, BitDepth: 24, SampleRate: 8000}, [][]int{{1}}, "NONE"), audio.PCMS24BE},
+		{"sowt", encode(t, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}, [][]int{{1}}, "sowt"), audio.PCMS16LE},
+		{"ulaw", ulaw, audio.ULAW},
+		{"alaw", alaw, audio.ALAW},
+	}
+	for _, tt := range tests {
+		c, err := aiff.Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		f, ok := c.(interface{ Format() audio.SampleFormat })
+		if !ok {
+			t.Fatalf("%s: %T doesn't report its format", tt.name, c)
+		}
+		if format := f.Format(); format != tt.expected {
+			t.Fatalf("%s: expected %s, got %s", tt.name, tt.expected, format)
+		}
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 22050}
+	frames := [][]int{{0, -1}, {32767, -32768}, {1234, -4321}, {-7, 7}, {100, 200}}
+	c, err := aiff.Decode(bytes.NewReader(encode(t, info, frames, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, gotInfo, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotInfo != info {
+		t.Fatalf("expected %s, got %s", info, gotInfo)
+	}
+	if !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected %v, got %v", frames, got)
+	}
+	if pos, _ := c.Seek(0, io.SeekCurrent); pos != 4 {
+		t.Fatalf("expected the read position to be restored, got %d", pos)
+	}
+}
+
+func TestSeekEndWithTrailingChunks(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := [][]int{{1}, {2}, {3}, {4}, {5}}
+	b := encode(t, info, frames, "")
+	b = appendChunk(b, "NAME", []byte("trailing name"))
+	b = append(b, markChunk(aiff.Marker{ID: 1, Position: 2, Name: "m"})...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	c, err := aiff.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos, err := c.Seek(-4, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 6 {
+		t.Fatalf("expected position 6, 4 bytes before the end of the sound data, got %d", pos)
+	}
+	p := make([]byte, 8)
+	n, _ := io.ReadFull(c, p)
+	if expected := []byte{0, 4, 0, 5}; !bytes.Equal(p[:n], expected) {
+		t.Fatalf("expected the last 2 samples %v, got %v", expected, p[:n])
+	}
+}
+
+func TestDecodeChannels(t *testing.T) {
+	info := audio.FrameInfo{Channels: 4, BitDepth: 16, SampleRate: 8000}
+	frames := make([][]int, 30)
+	for i := range frames {
+		frames[i] = []int{i, 100 + i, 200 + i, 300 + i}
+	}
+	b := encode(t, info, frames, "")
+	c, err := aiff.DecodeChannels(bytes.NewReader(b), []int{0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Channels() != 2 || c.Size() != 30*2*2 {
+		t.Fatalf("expected 30 stereo frames, got %d channel(s) and %d bytes", c.Channels(), c.Size())
+	}
+	got, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, frame := range got {
+		if expected := []int{i, 200 + i}; !reflect.DeepEqual(frame, expected) {
+			t.Fatalf("frame %d: expected %v, got %v", i, expected, frame)
+		}
+	}
+
+	// reads not aligned on frames keep the interleaving
+	if _, err := c.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 5)
+	if _, err := io.ReadFull(c, p); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0, 201, 0, 2, 0}; !bytes.Equal(p, expected) {
+		t.Fatalf("expected %v, got %v", expected, p)
+	}
+
+	for _, channels := range [][]int{nil, {4}, {-1}} {
+		if _, err := aiff.DecodeChannels(bytes.NewReader(b), channels); err == nil {
+			t.Fatalf("expected an error selecting channels %v", channels)
+		}
+	}
+}
+
+func TestWriteToWithProgress(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 1000)
+	b := encode(t, info, frames, "")
+	c, err := aiff.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip := c.(*aiff.Clip)
+	if err := clip.SetBlockSize(256); err != nil {
+		t.Fatal(err)
+	}
+	var progress []int64
+	buf := &bytes.Buffer{}
+	n, err := clip.WriteToWithProgress(buf, func(frame int64) {
+		progress = append(progress, frame)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != clip.Size() || int64(buf.Len()) != n {
+		t.Fatalf("expected %d bytes written, got %d", clip.Size(), n)
+	}
+	if len(progress) != 16 {
+		t.Fatalf("expected a callback per 64 frame block, got %d calls", len(progress))
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] <= progress[i-1] {
+			t.Fatalf("expected increasing frames, got %v", progress)
+		}
+	}
+	if last := progress[len(progress)-1]; last != clip.NumFrames() {
+		t.Fatalf("expected the last callback at frame %d, got %d", clip.NumFrames(), last)
+	}
+}
+
+func TestSplitToFiles(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 48000}
+	frames := testutil.Ramp(info, 500)
+	c, err := aiff.Decode(bytes.NewReader(encode(t, info, frames, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	segments := []aiff.Segment{{Start: 0, End: 120}, {Start: 300, End: 500}}
+	if err := aiff.SplitToFiles(c, segments, dir, "take"); err != nil {
+		t.Fatal(err)
+	}
+	for i, seg := range segments {
+		path := fmt.Sprintf("%s/take_%03d.aiff", dir, i)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := aiff.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		got, gotInfo, err := aiff.ReadAll(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotInfo != info || !reflect.DeepEqual(got, frames[seg.Start:seg.End]) {
+			t.Fatalf("%s: expected frames %d to %d, got %d frames of %s", path, seg.Start, seg.End, len(got), gotInfo)
+		}
+	}
+
+	if err := aiff.SplitToFiles(c, []aiff.Segment{{Start: 400, End: 600}}, dir, "bad"); err == nil {
+		t.Fatal("expected an error for a segment past the end of the clip")
+	}
+}
+
+func TestReadFloat32(t *testing.T) {
+	for _, bitDepth := range []int{16, 24} {
+		info := audio.FrameInfo{Channels: 2, BitDepth: bitDepth, SampleRate: 44100}
+		frames := testutil.Ramp(info, 301)
+		c, err := aiff.Decode(bytes.NewReader(encode(t, info, frames, "")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fullScale := math.Ldexp(1, bitDepth-1)
+		var got []float32
+		// an odd buffer size only gets whole frames
+		buf := make([]float32, 65)
+		for {
+			n, err := c.(*aiff.Clip).ReadFloat32(buf)
+			if n%2 != 0 {
+				t.Fatalf("%d-bit: expected whole frames, got %d samples", bitDepth, n)
+			}
+			got = append(got, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(got) != len(frames)*2 {
+			t.Fatalf("%d-bit: expected %d samples, got %d", bitDepth, len(frames)*2, len(got))
+		}
+		for i, v := range got {
+			expected := float64(frames[i/2][i%2]) / fullScale
+			if math.Abs(float64(v)-expected) > 1e-7 || v < -1 || v >= 1 {
+				t.Fatalf("%d-bit: sample %d expected %f, got %f", bitDepth, i, expected, v)
+			}
+		}
+	}
+}
+
+// recordingSink keeps a copy of the frames written to it.
+type recordingSink struct {
+	frames [][]int
+	blocks []int
+}
+
+func (s *recordingSink) Write(frames [][]int) error {
+	for _, frame := range frames {
+		s.frames = append(s.frames, append([]int(nil), frame...))
+	}
+	s.blocks = append(s.blocks, len(frames))
+	return nil
+}
+
+func TestPlay(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 250)
+	c, err := aiff.Decode(bytes.NewReader(encode(t, info, frames, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &recordingSink{}
+	if err := aiff.Play(c, s, 100); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.frames, frames) {
+		t.Fatalf("expected the %d frames in order, got %d", len(frames), len(s.frames))
+	}
+	if expected := []int{100, 100, 50}; !reflect.DeepEqual(s.blocks, expected) {
+		t.Fatalf("expected blocks of %v frames, got %v", expected, s.blocks)
+	}
+
+	if _, err := c.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := aiff.Play(c, aiff.NullSink{}, 64); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+package aiff
+
+import "io"
+
+// countingReadSeeker tracks how far into its reader the decoder went.
+type countingReadSeeker struct {
+	r io.ReadSeeker
+	// start is the position of r when wrapped, pos the current one and
+	// max the furthest one reached.
+	start, pos, max int64
+	// end is the size of r once known, seeking past it doesn't consume
+	// any byte.
+	end int64
+}
+
+func newCountingReadSeeker(r io.ReadSeeker) *countingReadSeeker {
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	return &countingReadSeeker{r: r, start: pos, pos: pos, max: pos, end: -1}
+}
+
+func (c *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pos += int64(n)
+	if c.pos > c.max {
+		c.max = c.pos
+	}
+	return n, err
+}
+
+func (c *countingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := c.r.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	c.pos = pos
+	// the decoder only seeks to the end to measure the stream
+	if whence == io.SeekEnd {
+		c.end = pos - offset
+		return pos, nil
+	}
+	if pos > c.max {
+		c.max = pos
+	}
+	return pos, nil
+}
+
+// consumed returns the number of bytes between the initial position and the
+// furthest position reached.
+func (c *countingReadSeeker) consumed() int64 {
+	if c.end >= 0 && c.max > c.end {
+		return c.end - c.start
+	}
+	return c.max - c.start
+}
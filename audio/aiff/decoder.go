@@ -1,19 +1,32 @@
 package aiff
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"sync"
 	"time"
 
 	"github.com/mattetti/exp/audio"
 )
 
+// Mode defines how the decoder deals with files not following the spec.
+type Mode int
+
+const (
+	// Lenient skips malformed chunks and decodes as much as possible,
+	// reporting the issues in Decoder.Warnings.
+	Lenient Mode = iota
+	// Strict returns an error on any spec violation.
+	Strict
+)
+
 // Decoder is the wrapper structure for the AIFF container
 type Decoder struct {
-	r io.Reader
+	r       io.ReadSeeker
+	counter *countingReadSeeker
 	// ID is always 'FORM'. This indicates that this is a FORM chunk
 	ID [4]byte
 	// Size contains the size of data portion of the 'FORM' chunk.
@@ -35,63 +48,513 @@ type Decoder struct {
 	// AIFC data
 	Encoding     [4]byte
 	EncodingName string
+
+	// UseSSNDForDuration makes Duration count the sample frames found in the
+	// SSND chunk instead of trusting the COMM frame count, which some
+	// encoders get wrong.
+	UseSSNDForDuration bool
+	// Warnings lists the non fatal issues found while decoding.
+	Warnings []string
+	// Mode defines how files not following the spec are handled,
+	// Lenient by default.
+	Mode Mode
+	// FillerBytes is the total size of the FLLR/JUNK filler chunks skipped.
+	FillerBytes int64
+	// UnknownChunks lists the IDs of the chunks the decoder skipped
+	// because it doesn't know how to parse them.
+	UnknownChunks [][4]byte
+	// HeaderOnly makes Decode parse the COMM and metadata chunks without
+	// reading the SSND chunk, which is skipped. The returned clip has its
+	// frame information set but no sound data, reducing I/O when indexing
+	// many files.
+	HeaderOnly bool
+	// SkippedBytes is the number of bytes skipped in Lenient mode to
+	// resynchronize on a known chunk after a chunk with an implausible size.
+	SkippedBytes int64
+	// FlattenNestedForms makes the decoder parse the chunks of a FORM
+	// nested in the main one, as written by a few tools, as if they were
+	// part of the main FORM. By default, a nested FORM is an ErrNestedForm
+	// error.
+	FlattenNestedForms bool
+	// SeekToFORM makes the decoder look for the FORM header within the
+	// first formScanLimit bytes instead of expecting it right away, so
+	// files with junk bytes prepended by some exporters still decode.
+	SeekToFORM bool
+
+	// Markers found in the MARK chunk
+	Markers []Marker
+	// Instrument is set when an INST chunk was found
+	Instrument *Instrument
+
+	// MIDI holds the MIDI data (Standard MIDI File content) of the MIDI
+	// chunks, concatenated when the file has several of them. Process
+	// preserves it, Encoder.SetMIDI writes it back to a new file.
+	MIDI []byte
+	// PeakInfo holds the peak of each channel stored in the PEAK chunk.
+	PeakInfo []PeakPoint
+	// Beats is the number of beats of a loop, as stored in the Apple Loops
+	// basc chunk, and Tempo the matching tempo in beats per minute derived
+	// from the duration of the file.
+	Beats int
+	Tempo float64
+	// Basc holds the raw content of a basc chunk whose layout isn't
+	// recognized.
+	Basc []byte
+
+	// AESChannelStatus is the AES3 channel status data of the AESD chunk
+	// written by professional recording gear.
+	AESChannelStatus [24]byte
+	hasAESD          bool
+
+	// header holds the raw FORM header once read
+	header []byte
+	// formEnd is the position following the FORM chunk
+	formEnd int64
+	// streamEnd is the position following the last byte of the stream
+	streamEnd int64
+	// positions of the COMM and SSND chunk payloads
+	commStart int64
+	ssndStart int64
+
+	// location of the sound data in the SSND chunk
+	dataStart int64
+	dataSize  int64
+	// SSND alignment fields
+	ssndOffset    uint32
+	ssndBlockSize uint32
+}
+
+// NewDecoder returns a decoder reading the AIFF content of r.
+func NewDecoder(r io.ReadSeeker) *Decoder {
+	counter := newCountingReadSeeker(r)
+	return &Decoder{r: counter, counter: counter}
+}
+
+// BytesRead returns the number of bytes of the input consumed so far, from
+// its position when the decoder was created to the furthest one reached,
+// skipped chunks included. It tells how far decoding went when it fails.
+func (d *Decoder) BytesRead() int64 {
+	if d.counter == nil {
+		return 0
+	}
+	return d.counter.consumed()
 }
 
 // Decode reads from a Read Seeker and converts the input to a PCM
-// clip output.
+// clip output. The input has to be the uncompressed AIFF data, compressed
+// files such as gzipped ones can be decoded with DecodeGzip.
 func Decode(r io.ReadSeeker) (audio.Clip, error) {
-	d := &Decoder{r: r}
+	return NewDecoder(r).Decode()
+}
+
+// Duration returns the duration of the AIFF data read from r, parsing its
+// headers without reading the sound data.
+func Duration(r io.ReadSeeker) (time.Duration, error) {
+	d := NewDecoder(r)
+	d.HeaderOnly = true
+	if _, err := d.Decode(); err != nil {
+		return 0, err
+	}
+	return d.Duration()
+}
+
+// Decode parses the AIFF container and returns the PCM clip it contains.
+// The decoder fields are populated with the parsed information.
+func (d *Decoder) Decode() (audio.Clip, error) {
 	if err := d.readHeaders(); err != nil {
 		return nil, err
 	}
+	if err := d.checkFormSize(); err != nil {
+		return nil, err
+	}
 
 	// read the file information to setup the audio clip
-	// find the beginning of the SSND chunk and set the clip reader to it.
-	clip := &Clip{}
-
-	var err error
-	var rewindBytes int64
-	for err != io.EOF {
-		id, size, err := d.iDnSize()
+	// and find the location of the sound data.
+	// The SSND chunk can come before the COMM chunk, the clip is
+	// only setup once all the chunks were read.
+	for {
+		done, err := d.step()
 		if err != nil {
+			return nil, err
+		}
+		if done {
 			break
 		}
-		switch id {
-		case commID:
-			d.parseCommChunk(size)
-			clip.channels = int(d.NumChans)
-			clip.bitDepth = int(d.SampleSize)
-			clip.sampleRate = int64(d.SampleRate)
-			// if we found the sound data before the COMM,
-			// we need to rewind the reader so we can properly
-			// set the clip reader.
-			if rewindBytes > 0 {
-				r.Seek(-rewindBytes, 1)
-				break
-			}
-		case ssndID:
-			clip.size = int64(size)
-			// if we didn't read the COMM, we are going to need to come back
-			if clip.sampleRate == 0 {
-				rewindBytes += int64(size)
-				if err := d.jumpTo(int(size)); err != nil {
-					return nil, err
-				}
-			} else {
-				break
+	}
+	d.setTempo()
+	if d.dsd() {
+		return nil, ErrUnsupportedDSD
+	}
+
+	if d.Mode == Strict {
+		if d.commSize == 0 {
+			return nil, fmt.Errorf("%s - missing COMM chunk", ErrUnexpectedData)
+		}
+		if d.Format == aiffID {
+			if err := d.checkFrameCount(); err != nil {
+				return nil, err
 			}
-		default:
-			// if we read SSN but didn't read the COMM, we need to track location
-			if clip.size != 0 {
-				rewindBytes += int64(size)
+		}
+	}
+	if d.UseSSNDForDuration {
+		if err := d.checkFrameCount(); err != nil {
+			d.Warnings = append(d.Warnings, err.Error())
+		}
+	}
+	if d.HeaderOnly {
+		return &Clip{
+			channels:   int(d.NumChans),
+			bitDepth:   int(d.SampleSize),
+			sampleRate: int64(d.SampleRate),
+			format:     d.sampleFormat(),
+		}, nil
+	}
+	var c audio.Clip = d.clip()
+	if decode := d.g711Decoder(); decode != nil {
+		c = newDecodedClip(d.clip(), decode)
+	}
+	if d.Mode == Strict && d.PeakInfo != nil {
+		if err := d.checkPeaks(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// step reads the next chunk header and parses the chunk, leaving the reader
+// on the following chunk. done is set once there are no more chunks to read.
+func (d *Decoder) step() (done bool, err error) {
+	before, err := d.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return true, err
+	}
+	// the bytes following the FORM chunk aren't chunks of the file
+	if before >= d.formEnd {
+		return true, nil
+	}
+	id, size, err := d.iDnSize()
+	if err != nil {
+		if err == io.EOF {
+			return true, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			if d.Mode == Strict {
+				return true, fmt.Errorf("%s - truncated chunk header", ErrUnexpectedData)
 			}
-			if err := d.jumpTo(int(size)); err != nil {
-				return nil, err
+			return true, nil
+		}
+		return true, err
+	}
+	start, err := d.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return true, err
+	}
+	// a chunk larger than what's left of the FORM is likely
+	// corrupted, look for the next chunk we know instead. The sound
+	// data of a truncated file is clamped when parsed.
+	// parsers allocate buffers of the chunk size, reject bogus sizes
+	// before reading them.
+	if d.Mode == Strict && start+int64(size) > d.formEnd {
+		return true, fmt.Errorf("%s - %s chunk of %d bytes overruns the FORM chunk", ErrUnexpectedData, id, size)
+	}
+	if d.Mode == Lenient && start+int64(size) > d.formEnd {
+		if id != ssndID {
+			return false, d.resync(start - 8)
+		}
+		// an understated FORM size would otherwise drop the audio
+		d.Warnings = append(d.Warnings, fmt.Sprintf("SSND chunk overruns the FORM chunk by %d bytes", start+int64(size)-d.formEnd))
+	}
+	if err := d.parseChunk(id, size); err != nil {
+		// without COMM, there is nothing to decode and skipping
+		// a nested FORM would skip the whole content
+		if d.Mode == Strict || id == commID || err == ErrNestedForm {
+			return true, err
+		}
+		d.Warnings = append(d.Warnings, fmt.Sprintf("%s chunk skipped - %s", id, err))
+		if _, err := d.r.Seek(start+int64(size), io.SeekStart); err != nil {
+			return true, err
+		}
+	}
+	// odd sized chunks are followed by a pad byte, the chunks of
+	// a flattened FORM are parsed instead of skipped.
+	if size%2 == 1 && id != formID {
+		if err := d.jumpTo(1); err != nil {
+			return true, err
+		}
+	}
+	// even empty chunks move the reader past their header, not moving
+	// forward would loop forever on the same chunk.
+	after, err := d.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return true, err
+	}
+	if after < before+8 {
+		return true, fmt.Errorf("%s - %s chunk at offset %d didn't move the decoder forward", ErrUnexpectedData, id, before)
+	}
+	return false, nil
+}
+
+// parseChunk parses the chunk with the passed ID, skipping unknown chunks.
+func (d *Decoder) parseChunk(id [4]byte, size uint32) error {
+	switch id {
+	case commID:
+		// the first COMM chunk wins, a second one contradicting it
+		// would change the meaning of the sound data already located.
+		if d.commSize != 0 {
+			if d.Mode == Strict {
+				return fmt.Errorf("%s - duplicate COMM chunk", ErrUnexpectedData)
 			}
+			d.Warnings = append(d.Warnings, "duplicate COMM chunk ignored")
+			return d.jumpTo(int(size))
 		}
+		return d.parseCommChunk(size)
+	case ssndID:
+		return d.parseSSNDChunk(size)
+	case markID:
+		return d.parseMarkChunk(size)
+	case instID:
+		return d.parseInstChunk(size)
+	case aesdID:
+		return d.parseAESDChunk(size)
+	case peakID:
+		return d.parsePeakChunk(size)
+	case midiID:
+		return d.parseMIDIChunk(size)
+	case bascID:
+		return d.parseBascChunk(size)
+	case formID:
+		return d.parseNestedForm(size)
+	case fllrID, junkID:
+		d.FillerBytes += int64(size)
+		return d.jumpTo(int(size))
+	default:
+		if fn, ok := chunkParser(id); ok {
+			return d.parseCustomChunk(size, fn)
+		}
+		d.UnknownChunks = append(d.UnknownChunks, id)
+		return d.jumpTo(int(size))
+	}
+}
+
+var (
+	chunkParsersMu sync.Mutex
+	chunkParsers   = map[[4]byte]func(r io.Reader, size uint32, d *Decoder) error{}
+)
+
+// RegisterChunkParser registers fn as the parser of the chunks with the
+// passed ID, letting applications read vendor chunks while decoding. fn is
+// called with a reader limited to the chunk payload, the payload size and
+// the decoder; the unread part of the payload is skipped once it returns.
+// The chunks the package parses itself can't be overridden.
+func RegisterChunkParser(id [4]byte, fn func(r io.Reader, size uint32, d *Decoder) error) {
+	chunkParsersMu.Lock()
+	chunkParsers[id] = fn
+	chunkParsersMu.Unlock()
+}
+
+// chunkParser returns the registered parser of the chunks with the passed ID.
+func chunkParser(id [4]byte) (func(r io.Reader, size uint32, d *Decoder) error, bool) {
+	chunkParsersMu.Lock()
+	defer chunkParsersMu.Unlock()
+	fn, ok := chunkParsers[id]
+	return fn, ok
+}
+
+// parseCustomChunk runs a registered parser on the current chunk.
+func (d *Decoder) parseCustomChunk(size uint32, fn func(r io.Reader, size uint32, d *Decoder) error) error {
+	start, err := d.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err := fn(io.LimitReader(d.r, int64(size)), size, d); err != nil {
+		return err
+	}
+	_, err = d.r.Seek(start+int64(size), io.SeekStart)
+	return err
+}
+
+// parseMIDIChunk keeps the raw MIDI data for MIDI parsers.
+func (d *Decoder) parseMIDIChunk(size uint32) error {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return fmt.Errorf("MIDI chunk failed to parse - %s", err)
+	}
+	d.MIDI = append(d.MIDI, data...)
+	return nil
+}
+
+// parseNestedForm reads the form type of a FORM chunk found inside the main
+// FORM, leaving the reader on its first chunk so the nested chunks are
+// parsed next.
+func (d *Decoder) parseNestedForm(size uint32) error {
+	if !d.FlattenNestedForms {
+		return ErrNestedForm
+	}
+	if size < 4 {
+		return fmt.Errorf("%s - %d bytes", ErrNestedForm, size)
+	}
+	var format [4]byte
+	if err := binary.Read(d.r, binary.BigEndian, &format); err != nil {
+		return err
+	}
+	if format != aiffID && format != aifcID {
+		return fmt.Errorf("%s - unsupported form type %s", ErrNestedForm, format)
+	}
+	return nil
+}
+
+// ssndFrames returns the number of sample frames found in the SSND chunk.
+func (d *Decoder) ssndFrames() int64 {
+	frameSize := d.frameInfo().BytesPerFrame()
+	if frameSize == 0 {
+		return 0
+	}
+	return d.dataSize / int64(frameSize)
+}
+
+// checkFrameCount reports an error when the COMM frame count and the amount
+// of sound data differ by more than 1%.
+func (d *Decoder) checkFrameCount() error {
+	comm, ssnd := int64(d.NumSampleFrames), d.ssndFrames()
+	diff := comm - ssnd
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff*100 > ssnd {
+		return fmt.Errorf("COMM declares %d sample frames but SSND contains %d", comm, ssnd)
+	}
+	return nil
+}
+
+// clip returns a clip covering the whole sound data.
+func (d *Decoder) clip() *Clip {
+	return &Clip{
+		Offset:     d.ssndOffset,
+		BlockSize:  d.ssndBlockSize,
+		r:          d.r,
+		start:      d.dataStart,
+		size:       d.dataSize,
+		channels:   int(d.NumChans),
+		bitDepth:   int(d.SampleSize),
+		sampleRate: int64(d.SampleRate),
+		format:     d.sampleFormat(),
+	}
+}
+
+// sampleFormat returns the encoding of the samples of the SSND chunk.
+func (d *Decoder) sampleFormat() audio.SampleFormat {
+	if d.Format != aifcID {
+		return pcmFormat(int(d.SampleSize))
+	}
+	switch d.Encoding {
+	case encNone, encTwos, encIn24, encIn32:
+		return pcmFormat(int(d.SampleSize))
+	case encSowt, enc42n1, enc23ni:
+		return pcmLEFormat(int(d.SampleSize))
+	case encFl32, encFL32:
+		return audio.Float32BE
+	case encFl64, encFL64:
+		return audio.Float64BE
+	case envUlaw, encULAW:
+		return audio.ULAW
+	case encAlaw, encALAW:
+		return audio.ALAW
+	}
+	return audio.UnknownFormat
+}
+
+// dsdMinSampleRate is the sample rate of DSD64, the lowest DSD rate.
+const dsdMinSampleRate = 64 * 44100
+
+// dsd reports whether the sound data is DSD, either flagged by the AIFC
+// encoding or stored as 1-bit samples at a DSD sample rate.
+func (d *Decoder) dsd() bool {
+	if d.Format == aifcID && (d.Encoding == encDSD || d.Encoding == encDsd) {
+		return true
+	}
+	return d.SampleSize == 1 && d.SampleRate >= dsdMinSampleRate
+}
+
+// g711Decoder returns the sample decoder of G.711 compressed AIFC data, nil
+// for other encodings.
+func (d *Decoder) g711Decoder() func(b byte) int16 {
+	if d.Format != aifcID {
+		return nil
+	}
+	switch d.Encoding {
+	case envUlaw, encULAW:
+		return ulawToLinear
+	case encAlaw, encALAW:
+		return alawToLinear
+	}
+	return nil
+}
+
+// section returns a clip covering the sound data between the passed
+// sample frames, decoded like the clip returned by Decode.
+func (d *Decoder) section(startFrame, endFrame int64) (audio.Clip, error) {
+	decode := d.g711Decoder()
+	if decode == nil {
+		c, err := d.clip().section(startFrame, endFrame)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	// compressed samples are stored on a single byte whatever the COMM
+	// sample size
+	c := d.clip()
+	frameSize := int64(c.channels)
+	if startFrame < 0 || startFrame > endFrame || endFrame*frameSize > c.size {
+		return nil, fmt.Errorf("invalid frame range %d-%d", startFrame, endFrame)
+	}
+	c.start += startFrame * frameSize
+	c.size = (endFrame - startFrame) * frameSize
+	return newDecodedClip(c, decode), nil
+}
+
+// String summarizes the decoded format, for instance:
+// "AIFC 2ch 16-bit 44100Hz sowt 1.5s".
+func (d *Decoder) String() string {
+	if d == nil {
+		return "<nil>"
+	}
+	b := make([]byte, 0, 48)
+	b = append(b, d.Format[:]...)
+	b = append(b, ' ')
+	b = append(b, d.frameInfo().String()...)
+	if d.Format == aifcID {
+		b = append(b, ' ')
+		b = append(b, d.Encoding[:]...)
+	}
+	if d.SampleRate > 0 {
+		dur, _ := d.Duration()
+		b = append(b, ' ')
+		b = append(b, dur.String()...)
+	}
+	return string(b)
+}
+
+// EncodingDescription describes the encoding of the sound data: the name
+// stored in the COMM chunk of AIFC files, or a description synthesized from
+// the sample format otherwise, for instance "16-bit PCM (big-endian)".
+func (d *Decoder) EncodingDescription() string {
+	if d.Format == aifcID && d.EncodingName != "" {
+		return d.EncodingName
+	}
+	switch f := d.sampleFormat(); f {
+	case audio.PCMS8, audio.PCMS16BE, audio.PCMS24BE, audio.PCMS32BE:
+		return fmt.Sprintf("%d-bit PCM (big-endian)", d.SampleSize)
+	case audio.PCMS16LE, audio.PCMS24LE, audio.PCMS32LE:
+		return fmt.Sprintf("%d-bit PCM (little-endian)", d.SampleSize)
+	case audio.UnknownFormat:
+		if d.Format != aifcID {
+			return fmt.Sprintf("%d-bit PCM (big-endian)", d.SampleSize)
+		}
+		return fmt.Sprintf("unknown encoding %q", d.Encoding[:])
+	default:
+		return f.String()
 	}
-	clip.r = r
-	return clip, nil
 }
 
 // Duration returns the time duration for the current AIFF container
@@ -99,15 +562,40 @@ func (d *Decoder) Duration() (time.Duration, error) {
 	if d == nil {
 		return 0, errors.New("can't calculate the duration of a nil pointer")
 	}
-	duration := time.Duration(float64(d.NumSampleFrames) / float64(d.SampleRate) * float64(time.Second))
+	frames := int64(d.NumSampleFrames)
+	if d.UseSSNDForDuration {
+		frames = d.ssndFrames()
+	}
+	if frames == 0 || d.SampleRate <= 0 {
+		return 0, nil
+	}
+	duration := time.Duration(float64(frames) / float64(d.SampleRate) * float64(time.Second))
 	return duration, nil
 }
 
+// frameInfo returns the frame information found in the COMM chunk.
+func (d *Decoder) frameInfo() audio.FrameInfo {
+	return audio.FrameInfo{
+		Channels:   int(d.NumChans),
+		BitDepth:   int(d.SampleSize),
+		SampleRate: int64(d.SampleRate),
+	}
+}
+
 func (d *Decoder) readHeaders() error {
+	if d.SeekToFORM {
+		if err := d.seekToFORM(); err != nil {
+			return err
+		}
+	}
 	if err := binary.Read(d.r, binary.BigEndian, &d.ID); err != nil {
 		return err
 	}
 	// Must start by a FORM header/ID
+	switch d.ID {
+	case riffID, rifxID, rf64ID:
+		return fmt.Errorf("%s - %s container, this is a WAV file and needs a WAV decoder", ErrFmtNotSupported, d.ID)
+	}
 	if d.ID != formID {
 		return fmt.Errorf("%s - %s", ErrFmtNotSupported, d.ID)
 	}
@@ -124,11 +612,87 @@ func (d *Decoder) readHeaders() error {
 		return fmt.Errorf("%s - %s", ErrFmtNotSupported, d.Format)
 	}
 
+	d.header = make([]byte, 12)
+	copy(d.header, d.ID[:])
+	binary.BigEndian.PutUint32(d.header[4:], d.Size)
+	copy(d.header[8:], d.Format[:])
 	return nil
 }
 
+// checkFormSize verifies that the stream is long enough to hold the
+// amount of data declared by the FORM header, which was just read.
+func (d *Decoder) checkFormSize() error {
+	pos, err := d.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	end, err := d.r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := d.r.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	// the form type is included in the FORM size
+	d.formEnd = pos - 4 + int64(d.Size)
+	d.streamEnd = end
+	available := end - pos + 4
+	if missing := int64(d.Size) - available; missing > 0 {
+		err := fmt.Errorf("%s - FORM declares %d bytes but only %d are available, %d bytes missing",
+			ErrTruncated, d.Size, available, missing)
+		if d.Mode == Strict {
+			return err
+		}
+		// a recording interrupted before the sizes were written, decode
+		// what is there.
+		d.Warnings = append(d.Warnings, err.Error())
+		d.formEnd = end
+	}
+	return nil
+}
+
+// formScanLimit is the number of bytes searched for the FORM header when
+// Decoder.SeekToFORM is set.
+const formScanLimit = 1024
+
+// seekToFORM moves the reader to the first FORM ID found within
+// formScanLimit bytes, leaving it in place when none is found.
+func (d *Decoder) seekToFORM() error {
+	start, err := d.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, formScanLimit+len(formID))
+	n, err := io.ReadFull(d.r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	var offset int64
+	if i := bytes.Index(buf[:n], formID[:]); i > 0 {
+		offset = int64(i)
+		d.Warnings = append(d.Warnings, fmt.Sprintf("%d bytes skipped before the FORM header", i))
+	}
+	_, err = d.r.Seek(start+offset, io.SeekStart)
+	return err
+}
+
+// Header returns the 12 bytes of the FORM header (ID, size and form type)
+// exactly as read from the file.
+func (d *Decoder) Header() ([]byte, error) {
+	if d == nil || d.header == nil {
+		return nil, errors.New("the FORM header wasn't read yet")
+	}
+	header := make([]byte, len(d.header))
+	copy(header, d.header)
+	return header, nil
+}
+
 func (d *Decoder) parseCommChunk(size uint32) error {
 	d.commSize = size
+	var err error
+	if d.commStart, err = d.r.Seek(0, io.SeekCurrent); err != nil {
+		return err
+	}
 
 	if err := binary.Read(d.r, binary.BigEndian, &d.NumChans); err != nil {
 		return fmt.Errorf("num of channels failed to parse - %s", err.Error())
@@ -150,22 +714,128 @@ func (d *Decoder) parseCommChunk(size uint32) error {
 			return fmt.Errorf("AIFC encoding failed to parse - %s", err)
 		}
 		// pascal style string with the description of the encoding
-		var size uint8
-		if err := binary.Read(d.r, binary.BigEndian, &size); err != nil {
-			return fmt.Errorf("AIFC encoding failed to parse - %s", err)
-		}
-
-		desc := make([]byte, size)
-		if err := binary.Read(d.r, binary.BigEndian, &desc); err != nil {
+		name, _, err := readPString(d.r)
+		if err != nil {
 			return fmt.Errorf("AIFC encoding failed to parse - %s", err)
 		}
-		d.EncodingName = string(desc)
+		d.EncodingName = name
 	}
 
 	return nil
 
 }
 
+// commBytes rebuilds the payload of the COMM chunk from the parsed fields,
+// including the AIFC encoding and its name for AIFC files. It is the payload
+// the encoder writes for the same format.
+func (d *Decoder) commBytes() []byte {
+	return commPayload(d.NumChans, d.NumSampleFrames, d.SampleSize, d.SampleRate,
+		d.Format == aifcID, d.Encoding, d.EncodingName)
+}
+
+// parseSSNDChunk records the location of the sound data and skips it.
+func (d *Decoder) parseSSNDChunk(size uint32) error {
+	var err error
+	if d.ssndStart, err = d.r.Seek(0, io.SeekCurrent); err != nil {
+		return err
+	}
+	// A file without sample frames can have a SSND chunk limited to
+	// its offset and block size fields (or even shorter).
+	if size <= 8 {
+		d.dataStart = d.ssndStart + int64(size)
+		d.dataSize = 0
+		return d.jumpTo(int(size))
+	}
+	// the data size is only used for the frame count checks
+	if d.HeaderOnly {
+		d.dataStart = d.ssndStart + 8
+		d.dataSize = int64(size) - 8
+		d.clampSSND()
+		return d.jumpTo(int(size))
+	}
+	var offset, blockSize uint32
+	if err := binary.Read(d.r, binary.BigEndian, &offset); err != nil {
+		return fmt.Errorf("SSND offset failed to parse - %s", err)
+	}
+	if err := binary.Read(d.r, binary.BigEndian, &blockSize); err != nil {
+		return fmt.Errorf("SSND block size failed to parse - %s", err)
+	}
+	pos, err := d.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	// the offset and block size fields aren't part of the sound data,
+	// neither is the leading offset.
+	dataSize := int64(size) - 8 - int64(offset)
+	if dataSize < 0 {
+		return fmt.Errorf("%s - SSND offset %d larger than the chunk", ErrUnexpectedData, offset)
+	}
+	d.dataStart = pos + int64(offset)
+	d.dataSize = dataSize
+	d.ssndOffset = offset
+	d.ssndBlockSize = blockSize
+	d.clampSSND()
+	return d.jumpTo(int(size - 8))
+}
+
+// clampSSND limits the sound data to the bytes available in the stream,
+// the SSND chunk of a truncated file declaring more data than there is.
+func (d *Decoder) clampSSND() {
+	missing := d.dataStart + d.dataSize - d.streamEnd
+	if missing <= 0 {
+		return
+	}
+	if missing > d.dataSize {
+		missing = d.dataSize
+	}
+	d.dataSize -= missing
+	d.Warnings = append(d.Warnings, fmt.Sprintf("SSND chunk truncated, %d bytes of sound data missing", missing))
+}
+
+// resyncIDs are the chunk IDs the decoder looks for when resynchronizing.
+var resyncIDs = [][4]byte{commID, ssndID, markID, instID, aesdID, peakID, midiID, bascID, fllrID, junkID}
+
+// resync scans the stream from the corrupted chunk starting at from and
+// positions the reader on the next known chunk ID, or at the end of the
+// FORM if none is found, always moving past the chunk header.
+func (d *Decoder) resync(from int64) error {
+	buf := make([]byte, defaultBlockSize)
+	// skip the ID of the corrupted chunk
+	pos := from + 4
+	next := d.formEnd
+	// a chunk starting beyond a bogus FORM size still needs to be
+	// skipped for the decoder to make progress
+	if next < from+8 {
+		next = from + 8
+	}
+	for found := false; !found && pos < d.formEnd; {
+		if _, err := d.r.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(d.r, buf)
+		if n < 4 {
+			break
+		}
+		for i := 0; i+4 <= n && !found; i++ {
+			for _, id := range resyncIDs {
+				if bytes.Equal(buf[i:i+4], id[:]) {
+					next, found = pos+int64(i), true
+					break
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+		// IDs can straddle two reads
+		pos += int64(n - 3)
+	}
+	d.SkippedBytes += next - from
+	d.Warnings = append(d.Warnings, fmt.Sprintf("corrupted chunk at offset %d, %d bytes skipped", from, next-from))
+	_, err := d.r.Seek(next, io.SeekStart)
+	return err
+}
+
 // iDnSize returns the next ID + block size
 func (d *Decoder) iDnSize() ([4]byte, uint32, error) {
 	var ID [4]byte
@@ -173,7 +843,7 @@ func (d *Decoder) iDnSize() ([4]byte, uint32, error) {
 	if err := binary.Read(d.r, binary.BigEndian, &ID); err != nil {
 		return ID, blockSize, err
 	}
-	if err := binary.Read(d.r, binary.BigEndian, &blockSize); err != err {
+	if err := binary.Read(d.r, binary.BigEndian, &blockSize); err != nil {
 		return ID, blockSize, err
 	}
 	return ID, blockSize, nil
@@ -183,7 +853,7 @@ func (d *Decoder) iDnSize() ([4]byte, uint32, error) {
 func (d *Decoder) jumpTo(bytesAhead int) error {
 	var err error
 	if bytesAhead > 0 {
-		_, err = io.CopyN(ioutil.Discard, d.r, int64(bytesAhead))
+		_, err = d.r.Seek(int64(bytesAhead), io.SeekCurrent)
 	}
 	return err
 }
@@ -0,0 +1,84 @@
+package aiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+)
+
+func TestCommBytes(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 44100}
+	for _, tt := range []struct {
+		aifc bool
+		name string
+	}{{false, ""}, {true, ""}, {true, "not compressed"}, {true, "odd"}} {
+		f, err := os.CreateTemp(t.TempDir(), "*.aif")
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := NewEncoder(f, info)
+		if tt.aifc {
+			if err := e.SetEncoding(encSowt, tt.name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.Write([][]int{{1, 2}, {3, 4}, {5, 6}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		b, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := NewDecoder(bytes.NewReader(b))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		i := bytes.Index(b, commID[:])
+		size := binary.BigEndian.Uint32(b[i+4:])
+		original := b[i+8 : i+8+int(size)]
+		if got := d.commBytes(); !bytes.Equal(got, original) {
+			t.Fatalf("%q: expected COMM payload %x, got %x", tt.name, original, got)
+		}
+	}
+}
+
+func TestReadPString(t *testing.T) {
+	for _, tt := range []struct {
+		data     string
+		expected string
+		consumed int
+	}{
+		// the count byte and the text are padded to an even length
+		{"\x00\x00next", "", 2},
+		{"\x01a" + "next", "a", 2},
+		{"\x02ab\x00next", "ab", 4},
+		{"\x03abcnext", "abc", 4},
+		{"\x04abcd\x00next", "abcd", 6},
+	} {
+		r := bytes.NewReader([]byte(tt.data))
+		s, n, err := readPString(r)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.data, err)
+		}
+		if s != tt.expected || n != tt.consumed {
+			t.Fatalf("%q: expected %q and %d bytes, got %q and %d", tt.data, tt.expected, tt.consumed, s, n)
+		}
+		if rest := tt.data[int(r.Size())-r.Len():]; rest != "next" {
+			t.Fatalf("%q: expected the reader on the next field, got %q", tt.data, rest)
+		}
+	}
+
+	for _, data := range []string{"", "\x05abc"} {
+		if _, _, err := readPString(bytes.NewReader([]byte(data))); err == nil {
+			t.Fatalf("%q: expected an error for a truncated string", data)
+		}
+	}
+}
@@ -0,0 +1,1067 @@
+package aiff_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+	"github.com/mattetti/exp/audio/aiff/testutil"
+)
+
+func TestDecodeTruncated(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 100)
+	b := encode(t, info, frames, "")
+	// cut in the middle of frame 60
+	b = b[:len(b)-40*4-2]
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	d.Mode = aiff.Strict
+	if _, err := d.Decode(); err == nil {
+		t.Fatal("expected an error decoding a truncated file in strict mode")
+	}
+
+	d = aiff.NewDecoder(bytes.NewReader(b))
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Warnings) == 0 {
+		t.Fatal("expected warnings about the truncation")
+	}
+	got, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, frames[:59]) {
+		t.Fatalf("expected the 59 complete frames, got %d frames", len(got))
+	}
+}
+
+func TestDecodeUnderstatedFormSize(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 500)
+	b := encode(t, info, frames, "")
+	// the FORM size only covers the COMM chunk and part of SSND
+	b[4], b[5], b[6], b[7] = 0, 0, 0, 60
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the %d frames, got %d", len(frames), len(got))
+	}
+	if len(d.Warnings) != 1 {
+		t.Fatalf("expected a single warning, got %q", d.Warnings)
+	}
+}
+
+func TestDecodeStrictHugeChunk(t *testing.T) {
+	for _, id := range []string{"MIDI", "PEAK", "basc"} {
+		b := encode(t, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}, [][]int{{1}}, "")
+		b = append(b, id...)
+		b = append(b, 0xff, 0xff, 0xff, 0xf0)
+		size := uint32(len(b) - 8)
+		b[4], b[5], b[6], b[7] = byte(size>>24), byte(size>>16), byte(size>>8), byte(size)
+
+		d := aiff.NewDecoder(bytes.NewReader(b))
+		d.Mode = aiff.Strict
+		if _, err := d.Decode(); err == nil {
+			t.Fatalf("expected an error for a %s chunk overrunning the file", id)
+		}
+	}
+}
+
+// markChunk returns a MARK chunk holding the passed markers.
+func markChunk(markers ...aiff.Marker) []byte {
+	payload := &bytes.Buffer{}
+	binary.Write(payload, binary.BigEndian, uint16(len(markers)))
+	for _, m := range markers {
+		binary.Write(payload, binary.BigEndian, m.ID)
+		binary.Write(payload, binary.BigEndian, m.Position)
+		payload.WriteByte(byte(len(m.Name)))
+		payload.WriteString(m.Name)
+		if len(m.Name)%2 == 0 {
+			payload.WriteByte(0)
+		}
+	}
+	chunk := &bytes.Buffer{}
+	aiff.WriteChunk(chunk, [4]byte{'M', 'A', 'R', 'K'}, payload.Bytes())
+	return chunk.Bytes()
+}
+
+func TestLoopClipUlaw(t *testing.T) {
+	inst := &bytes.Buffer{}
+	binary.Write(inst, binary.BigEndian, aiff.Instrument{
+		BaseNote:    60,
+		SustainLoop: aiff.Loop{PlayMode: aiff.ForwardLooping, BeginLoop: 1, EndLoop: 2},
+	})
+	instChunk := &bytes.Buffer{}
+	aiff.WriteChunk(instChunk, [4]byte{'I', 'N', 'S', 'T'}, inst.Bytes())
+	b := ulawFile([]byte{0xff, 0x80, 0x00, 0x7f},
+		markChunk(aiff.Marker{ID: 1, Position: 1}, aiff.Marker{ID: 2, Position: 3}),
+		instChunk.Bytes())
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	loop, err := d.LoopClip(aiff.SustainLoop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, info, err := aiff.ReadAll(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BitDepth != 16 {
+		t.Fatalf("expected decoded 16-bit samples, got %s", info)
+	}
+	if len(frames) != 2 || frames[0][0] <= 0 || frames[1][0] >= 0 {
+		t.Fatalf("expected the 2 decoded loop frames, got %v", frames)
+	}
+}
+
+func TestRegionBetweenMarkersUlaw(t *testing.T) {
+	b := ulawFile([]byte{0xff, 0x80, 0x00, 0x7f},
+		markChunk(aiff.Marker{ID: 1, Position: 1, Name: "in"}, aiff.Marker{ID: 2, Position: 3, Name: "out"}))
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	region, err := d.RegionBetweenMarkers(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, _, err := aiff.ReadAll(region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(frames, all[1:3]) {
+		t.Fatalf("expected frames %v, got %v", all[1:3], frames)
+	}
+}
+
+func TestDecoderString(t *testing.T) {
+	for _, tt := range []struct {
+		info     audio.FrameInfo
+		frames   int
+		encoding string
+		expected string
+	}{
+		{audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}, 66150, "", "AIFF 2ch 16-bit 44100Hz 1.5s"},
+		{audio.FrameInfo{Channels: 1, BitDepth: 24, SampleRate: 8000}, 2000, "sowt", "AIFC 1ch 24-bit 8000Hz sowt 250ms"},
+	} {
+		d := aiff.NewDecoder(bytes.NewReader(encode(t, tt.info, testutil.Ramp(tt.info, tt.frames), tt.encoding)))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if got := d.String(); got != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, got)
+		}
+	}
+	var d *aiff.Decoder
+	if got := d.String(); got != "<nil>" {
+		t.Errorf("expected <nil> for a nil decoder, got %q", got)
+	}
+}
+
+func TestDecodeEmptySSND(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	for _, ssnd := range []int{8, 0} {
+		b := encode(t, info, nil, "")
+		// shrink the SSND chunk, down to a chunk without its offset and
+		// block size fields
+		b = b[:len(b)-8+ssnd]
+		binary.BigEndian.PutUint32(b[len(b)-4-ssnd:], uint32(ssnd))
+		binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+		d := aiff.NewDecoder(bytes.NewReader(b))
+		d.Mode = aiff.Strict
+		c, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size() != 0 {
+			t.Fatalf("SSND of %d bytes: expected no sound data, got %d bytes", ssnd, c.Size())
+		}
+		if n, err := c.Read(make([]byte, 16)); n != 0 || err != io.EOF {
+			t.Fatalf("SSND of %d bytes: expected io.EOF, got %d bytes (%v)", ssnd, n, err)
+		}
+		if dur, err := d.Duration(); err != nil || dur != 0 {
+			t.Fatalf("SSND of %d bytes: expected a 0 duration, got %s (%v)", ssnd, dur, err)
+		}
+	}
+}
+
+func TestFingerprintIgnoresMetadata(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	fingerprint := func(opts testutil.TestOpts) [32]byte {
+		f, err := os.CreateTemp(t.TempDir(), "*.aif")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := testutil.WriteTestAIFF(f, info, opts); err != nil {
+			t.Fatal(err)
+		}
+		f.Seek(0, io.SeekStart)
+		c, err := aiff.Decode(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Seek(10, io.SeekStart)
+		sum, err := aiff.Fingerprint(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos, _ := c.Seek(0, io.SeekCurrent); pos != 10 {
+			t.Fatalf("expected Fingerprint to restore the position, got %d", pos)
+		}
+		return sum
+	}
+	a := fingerprint(testutil.TestOpts{Frames: 100, Name: "take 1"})
+	if b := fingerprint(testutil.TestOpts{Frames: 100, Name: "final mix", Author: "someone"}); a != b {
+		t.Fatal("expected files differing only by their metadata to have the same fingerprint")
+	}
+	if b := fingerprint(testutil.TestOpts{Frames: 99, Name: "take 1"}); a == b {
+		t.Fatal("expected a different fingerprint for different audio")
+	}
+}
+
+func TestDecodeEncodingNamePadding(t *testing.T) {
+	for _, name := range []string{"", "a", "ab", "not compressed"} {
+		comm := &bytes.Buffer{}
+		binary.Write(comm, binary.BigEndian, uint16(1))
+		binary.Write(comm, binary.BigEndian, uint32(2))
+		binary.Write(comm, binary.BigEndian, uint16(16))
+		rate := audio.IntToIeeeFloat(8000)
+		comm.Write(rate[:])
+		comm.WriteString("NONE")
+		comm.WriteByte(byte(len(name)))
+		comm.WriteString(name)
+		// the count byte and the text are padded to an even length
+		if len(name)%2 == 0 {
+			comm.WriteByte(0)
+		}
+
+		body := &bytes.Buffer{}
+		body.WriteString("AIFC")
+		aiff.WriteChunk(body, [4]byte{'C', 'O', 'M', 'M'}, comm.Bytes())
+		body.Write(markChunk(aiff.Marker{ID: 1, Position: 1, Name: "m"}))
+		aiff.WriteChunk(body, [4]byte{'S', 'S', 'N', 'D'}, append(make([]byte, 8), 0, 1, 0, 2))
+		b := append([]byte("FORM"), 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[4:], uint32(body.Len()))
+		b = append(b, body.Bytes()...)
+
+		d := aiff.NewDecoder(bytes.NewReader(b))
+		d.Mode = aiff.Strict
+		c, err := d.Decode()
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if d.EncodingName != name {
+			t.Fatalf("expected the encoding name %q, got %q", name, d.EncodingName)
+		}
+		if len(d.Markers) != 1 || d.Markers[0].Name != "m" {
+			t.Fatalf("%q: the chunk following COMM didn't parse, got markers %+v", name, d.Markers)
+		}
+		if frames, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(frames, [][]int{{1}, {2}}) {
+			t.Fatalf("%q: unexpected frames %v (%v)", name, frames, err)
+		}
+	}
+}
+
+func TestDecoderHeader(t *testing.T) {
+	d := aiff.NewDecoder(bytes.NewReader(nil))
+	if _, err := d.Header(); err == nil {
+		t.Fatal("expected an error before decoding")
+	}
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	for _, encoding := range []string{"", "sowt"} {
+		b := encode(t, info, testutil.Ramp(info, 3), encoding)
+		d := aiff.NewDecoder(bytes.NewReader(b))
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		header, err := d.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(header, b[:12]) {
+			t.Fatalf("expected %q, got %q", b[:12], header)
+		}
+		// the returned slice is a copy
+		header[0] = 'X'
+		if again, _ := d.Header(); again[0] != 'F' {
+			t.Fatal("modifying the returned header changed the decoder")
+		}
+	}
+}
+
+func TestUseSSNDForDuration(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	b := encode(t, info, testutil.Ramp(info, 1000), "")
+	// COMM claims twice the frames there are
+	binary.BigEndian.PutUint32(b[12+8+2:], 2000)
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if dur, _ := d.Duration(); dur != 250*time.Millisecond {
+		t.Fatalf("expected the COMM duration of 250ms, got %s", dur)
+	}
+
+	d = aiff.NewDecoder(bytes.NewReader(b))
+	d.UseSSNDForDuration = true
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if dur, _ := d.Duration(); dur != 125*time.Millisecond {
+		t.Fatalf("expected the SSND duration of 125ms, got %s", dur)
+	}
+	if len(d.Warnings) != 1 {
+		t.Fatalf("expected a warning about the frame count, got %q", d.Warnings)
+	}
+}
+
+// appendChunk returns the AIFF file b with a chunk added at the end of its
+// FORM chunk.
+func appendChunk(b []byte, id string, data []byte) []byte {
+	chunk := &bytes.Buffer{}
+	var fourCC [4]byte
+	copy(fourCC[:], id)
+	aiff.WriteChunk(chunk, fourCC, data)
+	b = append(append([]byte(nil), b...), chunk.Bytes()...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b
+}
+
+func TestDecodeAESD(t *testing.T) {
+	status := make([]byte, 24)
+	// professional format, 50/15µs emphasis, 48kHz
+	status[0] = 0x01 | 3<<2 | 2<<6
+	status[23] = 0xab
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 48000}
+	b := appendChunk(encode(t, info, testutil.Ramp(info, 4), ""), "AESD", status)
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	d.Mode = aiff.Strict
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.AESChannelStatus[:], status) {
+		t.Fatalf("expected the channel status %x, got %x", status, d.AESChannelStatus)
+	}
+	if rate := d.AESSampleRate(); rate != 48000 {
+		t.Fatalf("expected a 48kHz sample rate, got %d", rate)
+	}
+	if e := d.AESEmphasis(); e != aiff.Emphasis5015 {
+		t.Fatalf("expected the 50/15µs emphasis, got %d", e)
+	}
+
+	// consumer format channel status data isn't decoded
+	status[0] &^= 0x01
+	d = aiff.NewDecoder(bytes.NewReader(appendChunk(encode(t, info, nil, ""), "AESD", status)))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if d.AESSampleRate() != 0 || d.AESEmphasis() != aiff.EmphasisNotIndicated {
+		t.Fatal("expected the consumer format fields not to be decoded")
+	}
+}
+
+func TestDecodeModes(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 100)
+	good := encode(t, info, frames, "")
+	miscounted := append([]byte(nil), good...)
+	binary.BigEndian.PutUint32(miscounted[12+8+2:], 50)
+	duplicateCOMM := appendChunk(good, "COMM", good[12+8:12+8+18])
+
+	for name, b := range map[string][]byte{"frame count": miscounted, "duplicate COMM": duplicateCOMM} {
+		d := aiff.NewDecoder(bytes.NewReader(b))
+		d.Mode = aiff.Strict
+		if _, err := d.Decode(); err == nil {
+			t.Fatalf("%s: expected an error in strict mode", name)
+		}
+
+		d = aiff.NewDecoder(bytes.NewReader(b))
+		c, err := d.Decode()
+		if err != nil {
+			t.Fatalf("%s: expected lenient decoding to succeed, got %v", name, err)
+		}
+		got, _, err := aiff.ReadAll(c)
+		if err != nil || !reflect.DeepEqual(got, frames) {
+			t.Fatalf("%s: expected the %d frames, got %d (%v)", name, len(frames), len(got), err)
+		}
+	}
+}
+
+func TestDecodeFillerChunks(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 10)
+	b := encode(t, info, frames, "")
+	// odd sized filler chunks before COMM, followed by their pad byte
+	filler := &bytes.Buffer{}
+	aiff.WriteChunk(filler, [4]byte{'F', 'L', 'L', 'R'}, make([]byte, 5))
+	aiff.WriteChunk(filler, [4]byte{'J', 'U', 'N', 'K'}, make([]byte, 3))
+	b = append(append(append([]byte(nil), b[:12]...), filler.Bytes()...), b[12:]...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	d.Mode = aiff.Strict
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.NumChans != 1 || d.SampleRate != 8000 {
+		t.Fatalf("the COMM chunk following the filler didn't parse: %s", d)
+	}
+	if d.FillerBytes != 8 || len(d.UnknownChunks) != 0 {
+		t.Fatalf("expected 8 filler bytes and no unknown chunk, got %d and %q", d.FillerBytes, d.UnknownChunks)
+	}
+	if got, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("unexpected frames %v (%v)", got, err)
+	}
+}
+
+func TestMarkersByPosition(t *testing.T) {
+	b := ulawFile([]byte{0, 0, 0, 0}, markChunk(
+		aiff.Marker{ID: 1, Position: 3, Name: "c"},
+		aiff.Marker{ID: 2, Position: 0, Name: "a"},
+		aiff.Marker{ID: 3, Position: 2, Name: "b1"},
+		aiff.Marker{ID: 4, Position: 2, Name: "b2"},
+	))
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int16
+	for _, m := range d.MarkersByPosition() {
+		ids = append(ids, m.ID)
+	}
+	if expected := []int16{2, 3, 4, 1}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected markers %v, got %v", expected, ids)
+	}
+	if d.Markers[0].ID != 1 {
+		t.Fatalf("expected the decoder markers to keep their order, got %v", d.Markers)
+	}
+}
+
+func TestMarkerTime(t *testing.T) {
+	for _, tt := range []struct {
+		position   uint32
+		sampleRate int64
+		expected   time.Duration
+	}{
+		{44100, 44100, time.Second},
+		{22050, 44100, 500 * time.Millisecond},
+		{0, 44100, 0},
+		{44100, 0, 0},
+	} {
+		m := aiff.Marker{Position: tt.position}
+		if d := m.Time(tt.sampleRate); d != tt.expected {
+			t.Fatalf("frame %d at %dHz: expected %s, got %s", tt.position, tt.sampleRate, tt.expected, d)
+		}
+	}
+}
+
+func TestDecodeSSNDBlockAlignment(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 10)
+	b := encode(t, info, frames, "")
+	// align the sound data on 8 byte blocks, 4 padding bytes preceding it
+	i := bytes.Index(b, []byte("SSND"))
+	ssnd := append([]byte(nil), b[i:i+16]...)
+	binary.BigEndian.PutUint32(ssnd[4:], binary.BigEndian.Uint32(ssnd[4:])+4)
+	binary.BigEndian.PutUint32(ssnd[8:], 4)
+	binary.BigEndian.PutUint32(ssnd[12:], 8)
+	b = append(append(append(append([]byte(nil), b[:i]...), ssnd...), 0, 0, 0, 0), b[i+16:]...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	c, err := aiff.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clip := c.(*aiff.Clip)
+	if clip.Offset != 4 || clip.BlockSize != 8 {
+		t.Fatalf("expected offset 4 and block size 8, got %d and %d", clip.Offset, clip.BlockSize)
+	}
+	if got, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the frames following the offset, got %v (%v)", got, err)
+	}
+}
+
+func TestDecodeNestedForm(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 10)
+	inner := encode(t, info, frames, "")
+	b := append([]byte("FORM\x00\x00\x00\x00AIFF"), inner...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	if _, err := aiff.Decode(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), aiff.ErrNestedForm.Error()) {
+		t.Fatalf("expected a nested FORM error, got %v", err)
+	}
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	d.FlattenNestedForms = true
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the frames of the nested FORM, got %v (%v)", got, err)
+	}
+}
+
+// rangeReader records the byte ranges read from a ReadSeeker.
+type rangeReader struct {
+	io.ReadSeeker
+	ranges [][2]int64
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.ReadSeeker.Read(p)
+	r.ranges = append(r.ranges, [2]int64{pos, pos + int64(n)})
+	return n, err
+}
+
+func TestDecodeHeaderOnly(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 22050}
+	b := encode(t, info, testutil.Ramp(info, 500), "")
+	dataStart := int64(bytes.Index(b, []byte("SSND")) + 16)
+
+	r := &rangeReader{ReadSeeker: bytes.NewReader(b)}
+	d := aiff.NewDecoder(r)
+	d.HeaderOnly = true
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi := c.FrameInfo(); fi != info {
+		t.Fatalf("expected %s, got %s", info, fi)
+	}
+	if d.NumSampleFrames != 500 {
+		t.Fatalf("expected 500 sample frames, got %d", d.NumSampleFrames)
+	}
+	if c.Size() != 0 {
+		t.Fatalf("expected a clip without sound data, got %d bytes", c.Size())
+	}
+	for _, rg := range r.ranges {
+		if rg[1] > dataStart {
+			t.Fatalf("the sound data was read, bytes %d to %d", rg[0], rg[1])
+		}
+	}
+}
+
+func TestDecodeWAV(t *testing.T) {
+	for _, magic := range []string{"RIFF", "RIFX", "RF64"} {
+		b := []byte(magic + "\x24\x00\x00\x00WAVEfmt \x10\x00\x00\x00")
+		_, err := aiff.Decode(bytes.NewReader(b))
+		if err == nil || !strings.Contains(err.Error(), "WAV") {
+			t.Fatalf("%s: expected an error mentioning WAV, got %v", magic, err)
+		}
+	}
+}
+
+func TestDecodeGzip(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 1000)
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(encode(t, info, frames, "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := aiff.DecodeGzip(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, gotInfo, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotInfo != info || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the %d frames of %s, got %d of %s", len(frames), info, len(got), gotInfo)
+	}
+
+	if _, err := aiff.DecodeGzip(bytes.NewReader(encode(t, info, frames, ""))); err == nil {
+		t.Fatal("expected an error decoding uncompressed data")
+	}
+}
+
+func TestDecodeZeroSizeChunks(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 10)
+	b := encode(t, info, frames, "")
+	b = append(append(append([]byte(nil), b[:12]...), "APPL\x00\x00\x00\x00"...), b[12:]...)
+	b = appendChunk(b, "ZERO", nil)
+
+	for _, mode := range []aiff.Mode{aiff.Lenient, aiff.Strict} {
+		done := make(chan error, 1)
+		var got [][]int
+		go func() {
+			d := aiff.NewDecoder(bytes.NewReader(b))
+			d.Mode = mode
+			c, err := d.Decode()
+			if err == nil {
+				got, _, err = aiff.ReadAll(c)
+			}
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("mode %v: %v", mode, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("mode %v: decoding zero size chunks didn't terminate", mode)
+		}
+		if !reflect.DeepEqual(got, frames) {
+			t.Fatalf("mode %v: expected %v, got %v", mode, frames, got)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	b := encode(t, info, testutil.Ramp(info, 22050), "")
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := d.Duration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected != 500*time.Millisecond {
+		t.Fatalf("expected the decoder to report 500ms, got %s", expected)
+	}
+
+	r := &rangeReader{ReadSeeker: bytes.NewReader(b)}
+	duration, err := aiff.Duration(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duration != expected {
+		t.Fatalf("expected %s, got %s", expected, duration)
+	}
+	var read int64
+	for _, rg := range r.ranges {
+		read += rg[1] - rg[0]
+	}
+	if read > 100 {
+		t.Fatalf("expected only the headers to be read, got %d of %d bytes", read, len(b))
+	}
+}
+
+func TestDecodePeakChunk(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	frames := [][]int{{100, -200}, {16384, 0}, {-4000, -8192}}
+	withPeaks := func(peaks ...aiff.PeakPoint) []byte {
+		payload := &bytes.Buffer{}
+		binary.Write(payload, binary.BigEndian, [2]uint32{1, 0})
+		binary.Write(payload, binary.BigEndian, peaks)
+		chunk := &bytes.Buffer{}
+		aiff.WriteChunk(chunk, [4]byte{'P', 'E', 'A', 'K'}, payload.Bytes())
+		b := encode(t, info, frames, "")
+		i := bytes.Index(b, []byte("SSND"))
+		b = append(append(append([]byte(nil), b[:i]...), chunk.Bytes()...), b[i:]...)
+		binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+		return b
+	}
+	peaks := []aiff.PeakPoint{{Value: 0.5, Position: 1}, {Value: 0.25, Position: 2}}
+
+	for _, mode := range []aiff.Mode{aiff.Lenient, aiff.Strict} {
+		d := aiff.NewDecoder(bytes.NewReader(withPeaks(peaks...)))
+		d.Mode = mode
+		if _, err := d.Decode(); err != nil {
+			t.Fatalf("mode %v: %v", mode, err)
+		}
+		if !reflect.DeepEqual(d.PeakInfo, peaks) {
+			t.Fatalf("mode %v: expected %v, got %v", mode, peaks, d.PeakInfo)
+		}
+	}
+
+	// the stored peaks are only checked against the data in strict mode
+	wrong := withPeaks(aiff.PeakPoint{Value: 0.9, Position: 1}, peaks[1])
+	if _, err := aiff.Decode(bytes.NewReader(wrong)); err != nil {
+		t.Fatal(err)
+	}
+	d := aiff.NewDecoder(bytes.NewReader(wrong))
+	d.Mode = aiff.Strict
+	if _, err := d.Decode(); err == nil {
+		t.Fatal("expected an error for a PEAK chunk not matching the data")
+	}
+}
+
+func TestDecoderBytesRead(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	b := encode(t, info, testutil.Ramp(info, 50), "")
+	b = append(b, markChunk(aiff.Marker{ID: 1, Position: 5, Name: "m"})...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	if n := d.BytesRead(); n != 0 {
+		t.Fatalf("expected no bytes read before decoding, got %d", n)
+	}
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.BytesRead(); n != int64(len(b)) {
+		t.Fatalf("expected the %d bytes of the file to be consumed, got %d", len(b), n)
+	}
+
+	// decoding stops further into the file as more chunks are available
+	var last int64
+	for _, end := range []int{12, 20, 38, 60, len(b) - 10, len(b)} {
+		d := aiff.NewDecoder(bytes.NewReader(b[:end]))
+		d.Decode()
+		n := d.BytesRead()
+		if n < last || n > int64(end) {
+			t.Fatalf("%d bytes: expected %d to %d bytes read, got %d", end, last, end, n)
+		}
+		last = n
+	}
+	if last != int64(len(b)) {
+		t.Fatalf("expected the %d bytes to be read, got %d", len(b), last)
+	}
+}
+
+func TestMergeMarkers(t *testing.T) {
+	markers := []aiff.Marker{
+		{ID: 4, Position: 1000, Name: "spread"},
+		{ID: 1, Position: 10, Name: "first"},
+		{ID: 2, Position: 12, Name: "close"},
+		{ID: 3, Position: 19, Name: "chained"},
+		{ID: 5, Position: 1005, Name: "near spread"},
+		{ID: 6, Position: 2000, Name: "alone"},
+	}
+	tests := []struct {
+		within   uint32
+		expected []int16
+	}{
+		{0, []int16{1, 2, 3, 4, 5, 6}},
+		{5, []int16{1, 3, 4, 5, 6}},
+		{6, []int16{1, 3, 4, 6}},
+		{10, []int16{1, 4, 6}},
+		{5000, []int16{1}},
+	}
+	for _, tt := range tests {
+		merged := aiff.MergeMarkers(markers, tt.within)
+		var ids []int16
+		for _, m := range merged {
+			ids = append(ids, m.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.expected) {
+			t.Fatalf("within %d: expected markers %v, got %v", tt.within, tt.expected, ids)
+		}
+	}
+	if merged := aiff.MergeMarkers(markers, 10); merged[0].Name != "first" {
+		t.Fatalf("expected the first marker of a group to keep its name, got %q", merged[0].Name)
+	}
+	if markers[0].ID != 4 {
+		t.Fatal("expected the passed markers not to be reordered")
+	}
+}
+
+func TestDecodeConflictingCOMM(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 20)
+	b := encode(t, info, frames, "")
+	// a mono 8-bit 8kHz COMM chunk, before the sound data
+	other := encode(t, audio.FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}, nil, "")
+	comm := other[12 : 12+8+18]
+	b = append(append(append([]byte(nil), b[:12+8+18]...), comm...), b[12+8+18:]...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi := c.FrameInfo(); fi != info {
+		t.Fatalf("expected the first COMM chunk to win, got %s", fi)
+	}
+	if len(d.Warnings) != 1 || !strings.Contains(d.Warnings[0], "duplicate COMM") {
+		t.Fatalf("expected a duplicate COMM warning, got %q", d.Warnings)
+	}
+	if got, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the frames decoded with the first COMM chunk, got %v (%v)", got, err)
+	}
+
+	d = aiff.NewDecoder(bytes.NewReader(b))
+	d.Mode = aiff.Strict
+	if _, err := d.Decode(); err == nil || !strings.Contains(err.Error(), "duplicate COMM") {
+		t.Fatalf("expected a duplicate COMM error in strict mode, got %v", err)
+	}
+}
+
+func TestDecodeBascChunk(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	b := encode(t, info, testutil.Ramp(info, 16000), "")
+	basc := make([]byte, 84)
+	binary.BigEndian.PutUint32(basc, 1)
+	binary.BigEndian.PutUint32(basc[4:], 4)
+
+	d := aiff.NewDecoder(bytes.NewReader(appendChunk(b, "basc", basc)))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	// 4 beats in 2 seconds
+	if d.Beats != 4 || d.Tempo != 120 {
+		t.Fatalf("expected 4 beats at 120 BPM, got %d at %v", d.Beats, d.Tempo)
+	}
+	if d.Basc != nil {
+		t.Fatalf("expected a parsed chunk not to be kept raw, got %v", d.Basc)
+	}
+
+	binary.BigEndian.PutUint32(basc, 2)
+	d = aiff.NewDecoder(bytes.NewReader(appendChunk(b, "basc", basc)))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Beats != 0 || d.Tempo != 0 || !bytes.Equal(d.Basc, basc) {
+		t.Fatalf("expected an unknown layout to be kept raw, got %d beats and %d bytes", d.Beats, len(d.Basc))
+	}
+}
+
+func TestRegisterChunkParser(t *testing.T) {
+	var calls []uint32
+	var payload []byte
+	aiff.RegisterChunkParser([4]byte{'X', 'T', 'S', 'T'}, func(r io.Reader, size uint32, d *aiff.Decoder) error {
+		calls = append(calls, size)
+		// only part of the payload is read, the rest is skipped
+		payload = make([]byte, 2)
+		_, err := io.ReadFull(r, payload)
+		return err
+	})
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 10)
+	b := encode(t, info, frames, "")
+	chunk := &bytes.Buffer{}
+	aiff.WriteChunk(chunk, [4]byte{'X', 'T', 'S', 'T'}, []byte("vendr"))
+	b = append(append(append([]byte(nil), b[:12]...), chunk.Bytes()...), b[12:]...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	d.Mode = aiff.Strict
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []uint32{5}) || string(payload) != "ve" {
+		t.Fatalf("expected a call for the 5 byte chunk, got %v and %q", calls, payload)
+	}
+	if len(d.UnknownChunks) != 0 {
+		t.Fatalf("expected the chunk to be parsed, got unknown chunks %q", d.UnknownChunks)
+	}
+	if got, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the frames following the custom chunk, got %v (%v)", got, err)
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	dir := t.TempDir()
+	infos := []audio.FrameInfo{
+		{Channels: 1, BitDepth: 16, SampleRate: 8000},
+		{Channels: 2, BitDepth: 24, SampleRate: 48000},
+		{Channels: 2, BitDepth: 8, SampleRate: 22050},
+	}
+	var paths []string
+	for i, info := range infos {
+		path := fmt.Sprintf("%s/%d.aif", dir, i)
+		if err := os.WriteFile(path, encode(t, info, testutil.Ramp(info, int(info.SampleRate/2)), ""), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	bad := dir + "/bad.aif"
+	if err := os.WriteFile(bad, []byte("not an aiff file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the broken entries sit between valid ones
+	paths = []string{paths[0], bad, paths[1], dir + "/missing.aif", paths[2]}
+
+	results, err := aiff.DecodeBatch(paths, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+	for i, res := range results {
+		if res.Path != paths[i] {
+			t.Fatalf("result %d: expected %s, got %s", i, paths[i], res.Path)
+		}
+		if i == 1 || i == 3 {
+			if res.Err == nil {
+				t.Fatalf("%s: expected an error", res.Path)
+			}
+			continue
+		}
+		if res.Err != nil {
+			t.Fatalf("%s: %v", res.Path, res.Err)
+		}
+		if info := infos[i/2]; res.Info != info || res.Duration != 500*time.Millisecond {
+			t.Fatalf("%s: expected 500ms of %s, got %s of %s", res.Path, info, res.Duration, res.Info)
+		}
+	}
+	if _, err := aiff.DecodeBatch(paths, 0); err == nil {
+		t.Fatal("expected an error for a concurrency of 0")
+	}
+}
+
+func TestEncodingDescription(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	named := &bytes.Buffer{}
+	e := aiff.NewStreamEncoder(named, audio.FrameInfo{Channels: 1, BitDepth: 24, SampleRate: 8000})
+	if err := e.SetEncoding([4]byte{'N', 'O', 'N', 'E'}, "not compressed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Write([][]int{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"AIFF", encode(t, info, [][]int{{1}}, ""), "16-bit PCM (big-endian)"},
+		{"AIFF 8-bit", encode(t, audio.FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}, [][]int{{1}}, ""), "8-bit PCM (big-endian)"},
+		{"AIFC named", named.Bytes(), "not compressed"},
+		{"AIFC sowt", encode(t, info, [][]int{{1}}, "sowt"), "16-bit PCM (little-endian)"},
+		{"AIFC ulaw", ulawFile([]byte{0xff}), "u-law"},
+	} {
+		d := aiff.NewDecoder(bytes.NewReader(tt.data))
+		if _, err := d.Decode(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if desc := d.EncodingDescription(); desc != tt.expected {
+			t.Fatalf("%s: expected %q, got %q", tt.name, tt.expected, desc)
+		}
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	instChunk := func(inst aiff.Instrument) []byte {
+		payload := &bytes.Buffer{}
+		binary.Write(payload, binary.BigEndian, inst)
+		chunk := &bytes.Buffer{}
+		aiff.WriteChunk(chunk, [4]byte{'I', 'N', 'S', 'T'}, payload.Bytes())
+		return chunk.Bytes()
+	}
+	marks := markChunk(aiff.Marker{ID: 1, Position: 1}, aiff.Marker{ID: 2, Position: 3})
+	for _, tt := range []struct {
+		name    string
+		inst    aiff.Instrument
+		missing []string
+	}{
+		{"valid", aiff.Instrument{SustainLoop: aiff.Loop{PlayMode: aiff.ForwardLooping, BeginLoop: 1, EndLoop: 2}}, nil},
+		{"not played", aiff.Instrument{ReleaseLoop: aiff.Loop{PlayMode: aiff.NoLooping, BeginLoop: 7, EndLoop: 8}}, nil},
+		{"dangling", aiff.Instrument{
+			SustainLoop: aiff.Loop{PlayMode: aiff.ForwardLooping, BeginLoop: 1, EndLoop: 5},
+			ReleaseLoop: aiff.Loop{PlayMode: aiff.ForwardLooping, BeginLoop: 9, EndLoop: 2},
+		}, []string{"sustain loop end marker 5", "release loop begin marker 9"}},
+	} {
+		d := aiff.NewDecoder(bytes.NewReader(ulawFile([]byte{0, 0, 0, 0}, marks, instChunk(tt.inst))))
+		if _, err := d.Decode(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		err := d.ValidateReferences()
+		if tt.missing == nil {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.name)
+		}
+		for _, ref := range tt.missing {
+			if !strings.Contains(err.Error(), ref) {
+				t.Fatalf("%s: expected the error to list %q, got %v", tt.name, ref, err)
+			}
+		}
+	}
+}
+
+func TestDecodeDSD(t *testing.T) {
+	dsd64 := audio.FrameInfo{Channels: 2, BitDepth: 1, SampleRate: 64 * 44100}
+	for name, b := range map[string][]byte{
+		"DSD encoding": bytes.Replace(ulawFile([]byte{0x69, 0x96}), []byte("ulaw"), []byte("DSD "), 1),
+		"dsd encoding": bytes.Replace(ulawFile([]byte{0x69, 0x96}), []byte("ulaw"), []byte("dsd "), 1),
+		"1-bit DSD64":  encode(t, dsd64, [][]int{{0, -1}, {-1, 0}}, ""),
+	} {
+		if _, err := aiff.Decode(bytes.NewReader(b)); err != aiff.ErrUnsupportedDSD {
+			t.Fatalf("%s: expected ErrUnsupportedDSD, got %v", name, err)
+		}
+	}
+
+	// 1-bit PCM at a regular sample rate isn't DSD
+	b := encode(t, audio.FrameInfo{Channels: 1, BitDepth: 1, SampleRate: 8000}, [][]int{{0}, {-1}}, "")
+	if _, err := aiff.Decode(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeSeekToFORM(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := testutil.Ramp(info, 20)
+	b := encode(t, info, frames, "")
+	// 16 junk bytes starting with a UTF-8 BOM
+	junk := []byte("\xef\xbb\xbfjunk bytes..\x00")
+	prefixed := append(append([]byte(nil), junk...), b...)
+
+	if _, err := aiff.Decode(bytes.NewReader(prefixed)); err == nil {
+		t.Fatal("expected an error without SeekToFORM")
+	}
+	d := aiff.NewDecoder(bytes.NewReader(prefixed))
+	d.SeekToFORM = true
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the frames following the junk, got %v (%v)", got, err)
+	}
+
+	// the FORM header is only searched for near the start
+	far := append(make([]byte, 4096), b...)
+	d = aiff.NewDecoder(bytes.NewReader(far))
+	d.SeekToFORM = true
+	if _, err := d.Decode(); err == nil {
+		t.Fatal("expected an error for a FORM header 4096 bytes in")
+	}
+}
@@ -0,0 +1,105 @@
+package aiff
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// Insert returns an in-memory clip made of base with insert spliced in at
+// the passed frame. Both clips must have the same format. atFrame can range
+// from 0 (before the first frame) to the number of frames of base (after
+// the last one).
+func Insert(base, insert audio.Clip, atFrame int64) (audio.Clip, error) {
+	if !audio.SameFormat(base.FrameInfo(), insert.FrameInfo()) {
+		return nil, fmt.Errorf("%s - can't insert %s into %s", ErrFmtNotSupported, insert.FrameInfo(), base.FrameInfo())
+	}
+	baseFrames, err := readAllFrames(base)
+	if err != nil {
+		return nil, err
+	}
+	if atFrame < 0 || atFrame > int64(len(baseFrames)) {
+		return nil, fmt.Errorf("insertion frame %d out of range 0-%d", atFrame, len(baseFrames))
+	}
+	insertFrames, err := readAllFrames(insert)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([][]int, 0, len(baseFrames)+len(insertFrames))
+	frames = append(frames, baseFrames[:atFrame]...)
+	frames = append(frames, insertFrames...)
+	frames = append(frames, baseFrames[atFrame:]...)
+	return newMemClip(frames, base.FrameInfo()), nil
+}
+
+// Cut returns an in-memory clip made of c without the frames between
+// startFrame (included) and endFrame (excluded).
+func Cut(c audio.Clip, startFrame, endFrame int64) (audio.Clip, error) {
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	if startFrame < 0 || startFrame > endFrame || endFrame > int64(len(frames)) {
+		return nil, fmt.Errorf("invalid frame range %d-%d, the clip has %d frames", startFrame, endFrame, len(frames))
+	}
+	out := make([][]int, 0, int64(len(frames))-(endFrame-startFrame))
+	out = append(out, frames[:startFrame]...)
+	out = append(out, frames[endFrame:]...)
+	return newMemClip(out, c.FrameInfo()), nil
+}
+
+// SplitOnSilence splits c into the segments separated by at least
+// minSilenceFrames frames where all channels stay below thresholdDB (relative
+// to full scale). The leading and trailing silences are dropped. The
+// segments are sections of c reading its data, no samples are copied.
+func SplitOnSilence(c audio.Clip, thresholdDB float64, minSilenceFrames int64) ([]audio.Clip, error) {
+	if minSilenceFrames < 1 {
+		minSilenceFrames = 1
+	}
+	threshold := audio.DBToAmplitude(thresholdDB) * float64(maxSample(c.BitDepth())+1)
+	clip := asClip(c)
+	var segments []audio.Clip
+	// start and last non silent frame of the current segment
+	start, last := int64(-1), int64(-1)
+	closeSegment := func() error {
+		s, err := clip.section(start, last+1)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, s)
+		return nil
+	}
+	err := eachFrame(c, func(i int64, frame []int) error {
+		silent := true
+		for _, v := range frame {
+			if math.Abs(float64(v)) >= threshold {
+				silent = false
+				break
+			}
+		}
+		if silent {
+			return nil
+		}
+		if start >= 0 && i-last-1 >= minSilenceFrames {
+			if err := closeSegment(); err != nil {
+				return err
+			}
+			start = -1
+		}
+		if start < 0 {
+			start = i
+		}
+		last = i
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if start >= 0 {
+		if err := closeSegment(); err != nil {
+			return nil, err
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,133 @@
+package aiff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// numbered returns n mono frames holding first, first+1...
+func numbered(first, n int) [][]int {
+	frames := make([][]int, n)
+	for i := range frames {
+		frames[i] = []int{first + i}
+	}
+	return frames
+}
+
+func TestInsert(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	base, insert := numbered(0, 5), numbered(100, 3)
+	for _, tt := range []struct {
+		at       int64
+		expected [][]int
+	}{
+		{0, append(append([][]int{}, insert...), base...)},
+		{2, append(append(append([][]int{}, base[:2]...), insert...), base[2:]...)},
+		{5, append(append([][]int{}, base...), insert...)},
+	} {
+		c, err := Insert(newMemClip(base, info), newMemClip(insert, info), tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(frames, tt.expected) {
+			t.Fatalf("at %d: expected %v, got %v", tt.at, tt.expected, frames)
+		}
+	}
+
+	for _, at := range []int64{-1, 6} {
+		if _, err := Insert(newMemClip(base, info), newMemClip(insert, info), at); err == nil {
+			t.Fatalf("expected an error inserting at frame %d", at)
+		}
+	}
+	stereo := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	if _, err := Insert(newMemClip(base, info), newMemClip([][]int{{1, 2}}, stereo), 0); err == nil {
+		t.Fatal("expected an error inserting a clip of a different format")
+	}
+}
+
+func TestCut(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := numbered(0, 6)
+	for _, tt := range []struct {
+		start, end int64
+		expected   [][]int
+	}{
+		{0, 2, frames[2:]},
+		{2, 4, append(append([][]int{}, frames[:2]...), frames[4:]...)},
+		{4, 6, frames[:4]},
+		{3, 3, frames},
+		{0, 6, [][]int{}},
+	} {
+		c, err := Cut(newMemClip(frames, info), tt.start, tt.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Fatalf("cut %d-%d: expected %v, got %v", tt.start, tt.end, tt.expected, got)
+		}
+		if n := c.(*Clip).NumFrames(); n != int64(len(tt.expected)) {
+			t.Fatalf("cut %d-%d: expected %d frames, got %d", tt.start, tt.end, len(tt.expected), n)
+		}
+	}
+	for _, r := range [][2]int64{{-1, 2}, {3, 2}, {4, 7}} {
+		if _, err := Cut(newMemClip(frames, info), r[0], r[1]); err == nil {
+			t.Fatalf("expected an error cutting %d-%d", r[0], r[1])
+		}
+	}
+}
+
+func TestSplitOnSilence(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	// silence, a 100 frame tone, 300 silent frames, a 50 frame tone, silence
+	var frames [][]int
+	segment := func(n, level int) {
+		for i := 0; i < n; i++ {
+			v := level
+			if i%2 == 1 {
+				v = -level
+			}
+			frames = append(frames, []int{v})
+		}
+	}
+	segment(20, 0)
+	segment(100, 10000)
+	segment(300, 10)
+	segment(50, 8000)
+	segment(40, 0)
+
+	segments, err := SplitOnSilence(newMemClip(frames, info), -40, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(segments))
+	}
+	for i, expected := range [][][]int{frames[20:120], frames[420:470]} {
+		got, err := readAllFrames(segments[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("segment %d: expected %d frames, got %d", i, len(expected), len(got))
+		}
+	}
+
+	// a gap shorter than the minimum silence doesn't split the clip
+	segments, err = SplitOnSilence(newMemClip(frames, info), -40, 301)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || segments[0].(*Clip).NumFrames() != 450 {
+		t.Fatalf("expected a single 450 frame segment, got %d segments", len(segments))
+	}
+}
@@ -0,0 +1,252 @@
+package aiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// headerSize is the number of bytes written before the sound data of an
+// AIFF file: the FORM header, the COMM chunk and the SSND chunk header.
+const headerSize = 12 + 8 + 18 + 8 + 8
+
+// aifcVersion is the timestamp identifying the AIFC spec version, stored in
+// the FVER chunk.
+const aifcVersion = 0xA2805140
+
+// Encoder writes PCM frames to an AIFF file.
+type Encoder struct {
+	w    io.Writer
+	info audio.FrameInfo
+	// ws is set when the sizes can be back-patched once all the frames
+	// are written.
+	ws io.WriteSeeker
+	// start is the position of the FORM header in ws.
+	start int64
+	// buf holds the sound data of streaming encoders until Close.
+	buf *bytes.Buffer
+
+	frames      int64
+	wroteHeader bool
+
+	// encoding is set when writing an AIFC file
+	encoding     [4]byte
+	encodingName string
+	// midi is written in a MIDI chunk when set
+	midi []byte
+}
+
+// NewEncoder returns an encoder writing an AIFF file to w, starting at its
+// current position. The chunk sizes are back-patched when the encoder is
+// closed.
+func NewEncoder(w io.WriteSeeker, info audio.FrameInfo) *Encoder {
+	start, _ := w.Seek(0, io.SeekCurrent)
+	return &Encoder{w: w, ws: w, start: start, info: info}
+}
+
+// NewStreamEncoder returns an encoder writing an AIFF file to a plain writer
+// such as a pipe or a socket. The sizes have to be known before the sound
+// data is written so the whole output is buffered in memory and only written
+// to w when the encoder is closed.
+func NewStreamEncoder(w io.Writer, info audio.FrameInfo) *Encoder {
+	return &Encoder{w: w, info: info, buf: &bytes.Buffer{}}
+}
+
+// SetEncoding makes the encoder write an AIFC file using the passed
+// encoding, either 'NONE' (big endian PCM) or 'sowt' (little endian PCM),
+// and its human readable name, for instance "not compressed".
+// It has to be called before the first Write.
+func (e *Encoder) SetEncoding(fourCC [4]byte, name string) error {
+	if e.wroteHeader || e.frames > 0 {
+		return errors.New("aiff.Encoder.SetEncoding: called after Write")
+	}
+	if fourCC != encNone && fourCC != encSowt {
+		return fmt.Errorf("%s - can't encode %s data", ErrFmtNotSupported, fourCC)
+	}
+	if len(name) > 255 {
+		return fmt.Errorf("encoding name too long (%d bytes)", len(name))
+	}
+	e.encoding = fourCC
+	e.encodingName = name
+	return nil
+}
+
+// SetMIDI makes the encoder write the passed MIDI data (Standard MIDI File
+// content) in a MIDI chunk, for instance the Decoder.MIDI data of the file
+// being rewritten. It has to be called before the first Write.
+func (e *Encoder) SetMIDI(data []byte) error {
+	if e.wroteHeader || e.frames > 0 {
+		return errors.New("aiff.Encoder.SetMIDI: called after Write")
+	}
+	e.midi = append([]byte(nil), data...)
+	return nil
+}
+
+// aifc reports whether the encoder writes an AIFC file.
+func (e *Encoder) aifc() bool {
+	return e.encoding != [4]byte{}
+}
+
+// Write encodes the passed frames, each frame holding one sample per channel.
+func (e *Encoder) Write(frames [][]int) error {
+	if err := e.info.Validate(); err != nil {
+		return fmt.Errorf("%s - can't encode %s, %s", ErrFmtNotSupported, e.info, err)
+	}
+	if e.ws != nil && !e.wroteHeader {
+		if err := e.writeHeader(e.w); err != nil {
+			return err
+		}
+	}
+
+	data, err := encodeFrames(frames, e.info, e.encoding == encSowt)
+	if err != nil {
+		return err
+	}
+	if e.buf != nil {
+		_, err = e.buf.Write(data)
+	} else {
+		_, err = e.w.Write(data)
+	}
+	if err == nil {
+		e.frames += int64(len(frames))
+	}
+	return err
+}
+
+// encodeFrames returns the PCM data of the passed frames, using little endian
+// samples when swap is set.
+func encodeFrames(frames [][]int, info audio.FrameInfo, swap bool) ([]byte, error) {
+	sampleSize := sampleBytes(info.BitDepth)
+	data := make([]byte, len(frames)*info.Channels*sampleSize)
+	for i, frame := range frames {
+		if len(frame) != info.Channels {
+			return nil, fmt.Errorf("%s - frame %d has %d samples, expected %d", ErrUnexpectedData, i, len(frame), info.Channels)
+		}
+		for j, v := range frame {
+			offset := (i*info.Channels + j) * sampleSize
+			b := data[offset : offset+sampleSize]
+			encodeSample(b, v, info.BitDepth)
+			if swap {
+				for l, r := 0, len(b)-1; l < r; l, r = l+1, r-1 {
+					b[l], b[r] = b[r], b[l]
+				}
+			}
+		}
+	}
+	return data, nil
+}
+
+// Close finalizes the file, writing the chunk sizes.
+func (e *Encoder) Close() error {
+	if e.buf != nil {
+		if err := e.writeHeader(e.w); err != nil {
+			return err
+		}
+		if _, err := e.buf.WriteTo(e.w); err != nil {
+			return err
+		}
+		return e.writePad()
+	}
+
+	if !e.wroteHeader {
+		if err := e.writeHeader(e.w); err != nil {
+			return err
+		}
+	}
+	if err := e.writePad(); err != nil {
+		return err
+	}
+	end, err := e.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := e.ws.Seek(e.start, io.SeekStart); err != nil {
+		return err
+	}
+	if err := e.writeHeader(e.w); err != nil {
+		return err
+	}
+	_, err = e.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// dataSize returns the size of the sound data written so far.
+func (e *Encoder) dataSize() int64 {
+	return e.frames * int64(e.info.Channels*sampleBytes(e.info.BitDepth))
+}
+
+// writePad writes the pad byte required after an odd sized chunk.
+func (e *Encoder) writePad() error {
+	if e.dataSize()%2 == 0 {
+		return nil
+	}
+	_, err := e.w.Write([]byte{0})
+	return err
+}
+
+// writeHeader writes the FORM header, the FVER chunk of AIFC files, the COMM
+// chunk, the MIDI chunk if any and the SSND chunk header using the amount of
+// frames written so far.
+func (e *Encoder) writeHeader(w io.Writer) error {
+	dataSize := e.dataSize()
+
+	buf := bytes.NewBuffer(make([]byte, 0, headerSize))
+	buf.Write(formID[:])
+	// the FORM size is set once the header is complete
+	buf.Write(make([]byte, 4))
+	if e.aifc() {
+		buf.Write(aifcID[:])
+		fver := make([]byte, 4)
+		binary.BigEndian.PutUint32(fver, aifcVersion)
+		WriteChunk(buf, fverID, fver)
+	} else {
+		buf.Write(aiffID[:])
+	}
+
+	comm := commPayload(uint16(e.info.Channels), uint32(e.frames), uint16(e.info.BitDepth),
+		int(e.info.SampleRate), e.aifc(), e.encoding, e.encodingName)
+	WriteChunk(buf, commID, comm)
+	if e.midi != nil {
+		WriteChunk(buf, midiID, e.midi)
+	}
+
+	buf.Write(ssndID[:])
+	binary.Write(buf, binary.BigEndian, uint32(8+dataSize))
+	// offset and block size
+	binary.Write(buf, binary.BigEndian, uint32(0))
+	binary.Write(buf, binary.BigEndian, uint32(0))
+
+	header := buf.Bytes()
+	formSize := int64(len(header)) - 8 + dataSize + dataSize%2
+	binary.BigEndian.PutUint32(header[4:], uint32(formSize))
+
+	_, err := buf.WriteTo(w)
+	e.wroteHeader = true
+	return err
+}
+
+// commPayload returns the payload of a COMM chunk, followed by the encoding
+// and its name when aifc is set.
+func commPayload(channels uint16, frames uint32, sampleSize uint16, sampleRate int, aifc bool, encoding [4]byte, encodingName string) []byte {
+	comm := make([]byte, 18, 24+len(encodingName))
+	binary.BigEndian.PutUint16(comm[0:], channels)
+	binary.BigEndian.PutUint32(comm[2:], frames)
+	binary.BigEndian.PutUint16(comm[6:], sampleSize)
+	rate := audio.IntToIeeeFloat(sampleRate)
+	copy(comm[8:], rate[:])
+	if !aifc {
+		return comm
+	}
+	comm = append(comm, encoding[:]...)
+	comm = append(comm, byte(len(encodingName)))
+	comm = append(comm, encodingName...)
+	// the count byte and the text are padded to an even length
+	if len(encodingName)%2 == 0 {
+		comm = append(comm, 0)
+	}
+	return comm
+}
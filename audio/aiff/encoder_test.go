@@ -0,0 +1,128 @@
+package aiff_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+	"github.com/mattetti/exp/audio/aiff/testutil"
+)
+
+func TestEncodeAtOffset(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 9)
+	f, err := os.CreateTemp(t.TempDir(), "*.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	prefix := []byte("prefix!!")
+	if _, err := f.Write(prefix); err != nil {
+		t.Fatal(err)
+	}
+	e := aiff.NewEncoder(f, info)
+	if err := e.Write(frames); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, prefix) {
+		t.Fatalf("the bytes preceding the file were overwritten: %q", b[:len(prefix)])
+	}
+	r := bytes.NewReader(b)
+	if _, err := r.Seek(int64(len(prefix)), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	d := aiff.NewDecoder(r)
+	d.Mode = aiff.Strict
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected %v, got %v", frames, got)
+	}
+}
+
+func TestWriteChunk(t *testing.T) {
+	for _, tt := range []struct {
+		data     string
+		expected string
+	}{
+		{"", "TEST\x00\x00\x00\x00"},
+		{"ab", "TEST\x00\x00\x00\x02ab"},
+		{"abc", "TEST\x00\x00\x00\x03abc\x00"},
+	} {
+		buf := &bytes.Buffer{}
+		n, err := aiff.WriteChunk(buf, [4]byte{'T', 'E', 'S', 'T'}, []byte(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.expected {
+			t.Fatalf("expected %q, got %q", tt.expected, buf.String())
+		}
+		if n != len(tt.expected) {
+			t.Fatalf("%q: expected %d bytes written, got %d", tt.data, len(tt.expected), n)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := testutil.Ramp(info, 100)
+	markers := []aiff.Marker{{ID: 1, Position: 10, Name: "cue"}}
+	b := encode(t, info, frames[:60], "")
+	b = append(b, markChunk(markers...)...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	f, err := os.CreateTemp(t.TempDir(), "*.aif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range [][][]int{frames[60:61], frames[61:]} {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if err := aiff.Append(f, chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	d := aiff.NewDecoder(f)
+	d.Mode = aiff.Strict
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.NumSampleFrames != 100 {
+		t.Fatalf("expected 100 sample frames in COMM, got %d", d.NumSampleFrames)
+	}
+	if got, _, err := aiff.ReadAll(c); err != nil || !reflect.DeepEqual(got, frames) {
+		t.Fatalf("expected the %d frames, got %d (%v)", len(frames), len(got), err)
+	}
+	if !reflect.DeepEqual(d.Markers, markers) {
+		t.Fatalf("expected the trailing markers to be kept, got %v", d.Markers)
+	}
+}
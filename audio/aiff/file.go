@@ -0,0 +1,135 @@
+package aiff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// DecodeFile decodes the AIFF file at path by mapping it in memory, which
+// avoids copying very large files and gives the clip cheap random access.
+// The returned function releases the mapping and closes the file, the clip
+// can't be used once it was called.
+func DecodeFile(path string) (audio.Clip, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	data, unmap, err := mapFile(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	closeFn := func() error {
+		err := unmap()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	c, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		closeFn()
+		return nil, nil, err
+	}
+	return c, closeFn, nil
+}
+
+// DecodeGzip decompresses the gzipped AIFF data read from r into memory and
+// decodes it, the decoder needing to seek in the uncompressed data.
+func DecodeGzip(r io.Reader) (audio.Clip, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(bytes.NewReader(data))
+}
+
+// Segment is a range of sample frames, from Start (included) to End
+// (excluded).
+type Segment struct {
+	Start int64
+	End   int64
+}
+
+// SplitToFiles writes each segment of c as its own AIFF file in dir, named
+// after prefix and the index of the segment: prefix_000.aiff, prefix_001.aiff
+// and so on. The frames are streamed from c to the files block by block.
+// The read position of c is restored afterwards.
+func SplitToFiles(c audio.Clip, segments []Segment, dir, prefix string) error {
+	if err := c.FrameInfo().Validate(); err != nil {
+		return fmt.Errorf("%s - %s", ErrFmtNotSupported, err)
+	}
+	pos, err := c.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	defer c.Seek(pos, io.SeekStart)
+
+	clip := asClip(c)
+	sections := make([]*Clip, len(segments))
+	for i, seg := range segments {
+		if sections[i], err = clip.section(seg.Start, seg.End); err != nil {
+			return fmt.Errorf("segment %d: %s", i, err)
+		}
+	}
+	for i, s := range sections {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%03d.aiff", prefix, i))
+		if err := writeFile(path, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile encodes all the frames of c to a new AIFF file at path.
+func writeFile(path string, c *Clip) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	e := NewEncoder(f, c.FrameInfo())
+	blockFrames := c.blockBytes() / c.FrameInfo().BytesPerFrame()
+	if blockFrames < 1 {
+		blockFrames = 1
+	}
+	block := make([][]int, blockFrames)
+	for {
+		n, err := c.ReadFrames(block)
+		if n > 0 {
+			if werr := e.Write(block[:n]); werr != nil {
+				f.Close()
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := e.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,281 @@
+package aiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// asClip returns a Clip decoding the PCM data of c.
+// Clips not created by this package are expected to contain
+// big endian signed PCM data.
+func asClip(c audio.Clip) *Clip {
+	if clip, ok := c.(*Clip); ok {
+		return clip
+	}
+	return &Clip{
+		r:          c,
+		size:       c.Size(),
+		channels:   c.Channels(),
+		bitDepth:   c.BitDepth(),
+		sampleRate: c.SampleRate(),
+	}
+}
+
+// ReadAll decodes all the frames of c, each frame holding one sample per
+// channel, and returns them with the frame information of the clip.
+// The read position of c is restored afterwards.
+func ReadAll(c audio.Clip) ([][]int, audio.FrameInfo, error) {
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, audio.FrameInfo{}, err
+	}
+	return frames, c.FrameInfo(), nil
+}
+
+// checkSupported returns an error describing why the DSP transforms can't
+// process clips of the passed format: they only handle 8, 16, 24 and 32-bit
+// samples at a valid sample rate.
+func checkSupported(fi audio.FrameInfo) error {
+	if err := fi.Validate(); err != nil {
+		return fmt.Errorf("%s - %s", ErrFmtNotSupported, err)
+	}
+	switch fi.BitDepth {
+	case 8, 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("%s - can't process %d-bit samples, only 8, 16, 24 and 32-bit ones", ErrFmtNotSupported, fi.BitDepth)
+}
+
+// readAllFrames decodes all the frames of c, starting from the beginning of
+// the clip. Clips with an invalid frame info are rejected.
+// The read position of c is restored afterwards.
+func readAllFrames(c audio.Clip) ([][]int, error) {
+	if err := c.FrameInfo().Validate(); err != nil {
+		return nil, fmt.Errorf("%s - %s", ErrFmtNotSupported, err)
+	}
+	pos, err := c.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Seek(pos, io.SeekStart)
+	if _, err := c.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	clip := asClip(c)
+	frameSize := int64(sampleBytes(c.BitDepth()) * c.Channels())
+	if frameSize == 0 {
+		return nil, ErrFmtNotSupported
+	}
+	frames := make([][]int, c.Size()/frameSize)
+	blockFrames := clip.blockBytes() / int(frameSize)
+	if blockFrames < 1 {
+		blockFrames = 1
+	}
+	var n int
+	for n < len(frames) {
+		end := n + blockFrames
+		if end > len(frames) {
+			end = len(frames)
+		}
+		read, err := clip.ReadFrames(frames[n:end])
+		n += read
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return frames[:n], nil
+}
+
+// eachFrame decodes c block by block from the beginning of the clip and calls
+// fn with the index and samples of every frame. The frame slice is reused
+// between calls. The read position of c is restored afterwards.
+func eachFrame(c audio.Clip, fn func(i int64, frame []int) error) error {
+	pos, err := c.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	defer c.Seek(pos, io.SeekStart)
+	if _, err := c.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	clip := asClip(c)
+	frameSize := sampleBytes(c.BitDepth()) * c.Channels()
+	if frameSize == 0 {
+		return ErrFmtNotSupported
+	}
+	blockFrames := clip.blockBytes() / frameSize
+	if blockFrames < 1 {
+		blockFrames = 1
+	}
+	block := make([][]int, blockFrames)
+	var i int64
+	for {
+		n, err := clip.ReadFrames(block)
+		for _, frame := range block[:n] {
+			if err := fn(i, frame); err != nil {
+				return err
+			}
+			i++
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// newMemClip returns an in-memory clip containing the passed frames
+// encoded as big endian PCM data.
+func newMemClip(frames [][]int, info audio.FrameInfo) *Clip {
+	sampleSize := sampleBytes(info.BitDepth)
+	data := make([]byte, len(frames)*info.Channels*sampleSize)
+	for i, frame := range frames {
+		for j, v := range frame {
+			offset := (i*info.Channels + j) * sampleSize
+			encodeSample(data[offset:offset+sampleSize], v, info.BitDepth)
+		}
+	}
+	return &Clip{
+		r:          bytes.NewReader(data),
+		size:       int64(len(data)),
+		channels:   info.Channels,
+		bitDepth:   info.BitDepth,
+		sampleRate: info.SampleRate,
+	}
+}
+
+// encodeSample writes v as a big endian, left-justified sample into b.
+func encodeSample(b []byte, v int, bitDepth int) {
+	u := uint32(clamp(v, bitDepth) << uint(len(b)*8-bitDepth))
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(u)
+		u >>= 8
+	}
+}
+
+// maxSample returns the largest value a sample of the given bit depth can hold.
+func maxSample(bitDepth int) int {
+	return 1<<uint(bitDepth-1) - 1
+}
+
+// minSample returns the smallest value a sample of the given bit depth can hold.
+func minSample(bitDepth int) int {
+	return -1 << uint(bitDepth-1)
+}
+
+// clamp limits v to the range of a sample of the given bit depth.
+func clamp(v, bitDepth int) int {
+	if max := maxSample(bitDepth); v > max {
+		return max
+	}
+	if min := minSample(bitDepth); v < min {
+		return min
+	}
+	return v
+}
+
+// toFloatFrames converts frames to float64 values, keeping their scale.
+func toFloatFrames(frames [][]int) [][]float64 {
+	out := make([][]float64, len(frames))
+	for i, frame := range frames {
+		out[i] = make([]float64, len(frame))
+		for j, v := range frame {
+			out[i][j] = float64(v)
+		}
+	}
+	return out
+}
+
+// toIntFrames rounds float frames back to samples of the given bit depth,
+// clamping values out of range.
+func toIntFrames(frames [][]float64, bitDepth int) [][]int {
+	out, _ := toIntFramesOverflow(frames, bitDepth, OverflowClamp)
+	return out
+}
+
+// Overflow is the policy applied to samples exceeding the range of their
+// bit depth after processing.
+type Overflow int
+
+const (
+	// OverflowClamp limits the samples to the range of the bit depth.
+	OverflowClamp Overflow = iota
+	// OverflowWrap lets the samples wrap around like integers do,
+	// which can be used as a creative distortion.
+	OverflowWrap
+	// OverflowError reports an ErrOverflow.
+	OverflowError
+)
+
+// apply maps v to the range of the bit depth following the policy.
+func (o Overflow) apply(v, bitDepth int) (int, error) {
+	if v <= maxSample(bitDepth) && v >= minSample(bitDepth) {
+		return v, nil
+	}
+	switch o {
+	case OverflowWrap:
+		shift := uint(64 - bitDepth)
+		return int(int64(v) << shift >> shift), nil
+	case OverflowError:
+		return 0, fmt.Errorf("%s - %d doesn't fit in %d bits", ErrOverflow, v, bitDepth)
+	}
+	return clamp(v, bitDepth), nil
+}
+
+// outputDepth returns the bit depth of the clips produced by processing c:
+// the one set with SetOutputBitDepth or the bit depth of c.
+func outputDepth(c audio.Clip) int {
+	if clip, ok := c.(*Clip); ok && clip.outDepth > 0 {
+		return clip.outDepth
+	}
+	return c.BitDepth()
+}
+
+// fromFloatFrames returns an in-memory clip with the samples of signal, a
+// float signal at the scale of the bit depth of c, rounded to the output bit
+// depth of c. info is the frame information of the result, its bit depth
+// being replaced. Out of range values are handled following o.
+func fromFloatFrames(signal [][]float64, c audio.Clip, info audio.FrameInfo, o Overflow) (*Clip, error) {
+	depth := outputDepth(c)
+	if shift := depth - c.BitDepth(); shift != 0 {
+		scale := math.Ldexp(1, shift)
+		for _, frame := range signal {
+			for j := range frame {
+				frame[j] *= scale
+			}
+		}
+	}
+	info.BitDepth = depth
+	frames, err := toIntFramesOverflow(signal, depth, o)
+	if err != nil {
+		return nil, err
+	}
+	return newMemClip(frames, info), nil
+}
+
+// toIntFramesOverflow is like toIntFrames but handles out of range values
+// following the passed policy.
+func toIntFramesOverflow(frames [][]float64, bitDepth int, o Overflow) ([][]int, error) {
+	out := make([][]int, len(frames))
+	for i, frame := range frames {
+		out[i] = make([]int, len(frame))
+		for j, v := range frame {
+			var err error
+			if out[i][j], err = o.apply(int(math.Floor(v+0.5)), bitDepth); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,36 @@
+package aiff
+
+import (
+	"bytes"
+	"io"
+)
+
+// fuzzDecode decodes data in both modes and reads the sound data, returning
+// 1 when the input is a valid file in Strict mode. Decoding arbitrary data
+// must never panic.
+func fuzzDecode(data []byte) int {
+	for _, mode := range []Mode{Lenient, Strict} {
+		d := NewDecoder(bytes.NewReader(data))
+		d.Mode = mode
+		d.FlattenNestedForms = mode == Lenient
+		c, err := d.Decode()
+		if err != nil {
+			continue
+		}
+		_ = d.String()
+		frames := make([][]int, 64)
+		clip := asClip(c)
+		for {
+			if _, err := clip.ReadFrames(frames); err != nil {
+				break
+			}
+		}
+		if _, err := c.Seek(0, io.SeekStart); err != nil {
+			return 0
+		}
+		if mode == Strict {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,14 @@
+//go:build gofuzz
+// +build gofuzz
+
+package aiff
+
+// Fuzz is the go-fuzz entry point, the seed corpus lives in fuzz/corpus:
+//
+//	go-fuzz-build github.com/mattetti/exp/audio/aiff
+//	go-fuzz -bin=aiff-fuzz.zip -workdir=fuzz
+//
+// The same corpus seeds the native FuzzDecode target.
+func Fuzz(data []byte) int {
+	return fuzzDecode(data)
+}
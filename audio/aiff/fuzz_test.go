@@ -0,0 +1,79 @@
+package aiff
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fuzzCorpus returns the files of the go-fuzz seed corpus.
+func fuzzCorpus(t testing.TB) [][]byte {
+	paths, err := filepath.Glob(filepath.Join("fuzz", "corpus", "*.aif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("empty seed corpus")
+	}
+	var corpus [][]byte
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corpus = append(corpus, b)
+	}
+	return corpus
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, b := range fuzzCorpus(f) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDecode(data)
+	})
+}
+
+func TestDecodeCorpus(t *testing.T) {
+	for i, b := range fuzzCorpus(t) {
+		if fuzzDecode(b) != 1 {
+			t.Errorf("seed %d doesn't decode in strict mode", i)
+		}
+	}
+}
+
+func TestDecodeTruncatedInputs(t *testing.T) {
+	for i, b := range fuzzCorpus(t) {
+		for n := 0; n < len(b); n++ {
+			d := NewDecoder(bytes.NewReader(b[:n]))
+			d.Mode = Strict
+			if _, err := d.Decode(); err == nil {
+				t.Fatalf("seed %d truncated to %d bytes: expected an error", i, n)
+			}
+			// lenient decoding may salvage the data but mustn't panic
+			fuzzDecode(b[:n])
+		}
+	}
+}
+
+func TestDecodeRandomInputs(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		data := make([]byte, rnd.Intn(512))
+		rnd.Read(data)
+		// a valid header lets the random data reach the chunk parsers
+		if i%2 == 1 && len(data) >= 12 {
+			copy(data, "FORM")
+			copy(data[8:], "AIFF")
+		}
+		d := NewDecoder(bytes.NewReader(data))
+		d.Mode = Strict
+		if _, err := d.Decode(); err == nil {
+			t.Fatalf("input %d: expected an error decoding random data", i)
+		}
+		fuzzDecode(data)
+	}
+}
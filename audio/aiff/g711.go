@@ -0,0 +1,113 @@
+package aiff
+
+import (
+	"io"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// decodedClip decompresses the AIFC data of its source clip on read and
+// exposes it as 16-bit big endian linear PCM.
+type decodedClip struct {
+	// src holds the compressed data, one byte per sample.
+	src    *Clip
+	decode func(b byte) int16
+	// pos is the read position in the decoded data.
+	pos int64
+}
+
+// newDecodedClip returns a clip decoding the compressed samples of src with
+// the passed sample decoder.
+func newDecodedClip(src *Clip, decode func(b byte) int16) *decodedClip {
+	return &decodedClip{src: src, decode: decode}
+}
+
+// Format returns the compressed encoding of the source samples.
+func (c *decodedClip) Format() audio.SampleFormat {
+	return c.src.Format()
+}
+
+func (c *decodedClip) Read(p []byte) (n int, err error) {
+	if c.pos >= c.Size() {
+		return 0, io.EOF
+	}
+	// a read can start in the middle of a decoded sample
+	skip := int(c.pos % 2)
+	compressed := make([]byte, (len(p)+skip+1)/2)
+	if _, err := c.src.Seek(c.pos/2, io.SeekStart); err != nil {
+		return 0, err
+	}
+	read, err := c.src.Read(compressed)
+	decoded := make([]byte, 2*read)
+	for i, b := range compressed[:read] {
+		v := c.decode(b)
+		decoded[2*i] = byte(uint16(v) >> 8)
+		decoded[2*i+1] = byte(v)
+	}
+	if skip > len(decoded) {
+		skip = len(decoded)
+	}
+	n = copy(p, decoded[skip:])
+	c.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (c *decodedClip) Seek(offset int64, whence int) (int64, error) {
+	abs, err := seekPos(c.pos, c.Size(), offset, whence, "aiff.decodedClip.Seek")
+	if err != nil {
+		return 0, err
+	}
+	c.pos = abs
+	return abs, nil
+}
+
+func (c *decodedClip) FrameInfo() audio.FrameInfo {
+	return audio.FrameInfo{
+		Channels:   c.src.channels,
+		BitDepth:   16,
+		SampleRate: c.src.sampleRate,
+	}
+}
+
+func (c *decodedClip) Channels() int     { return c.src.channels }
+func (c *decodedClip) SampleRate() int64 { return c.src.sampleRate }
+func (c *decodedClip) BitDepth() int     { return 16 }
+
+// Size returns the size of the decoded data.
+func (c *decodedClip) Size() int64 {
+	return c.src.size * 2
+}
+
+// ulawToLinear decodes a G.711 µ-law sample.
+func ulawToLinear(u byte) int16 {
+	u = ^u
+	t := (int(u&0x0F) << 3) + 0x84
+	t <<= (uint(u) & 0x70) >> 4
+	if u&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+	return int16(t - 0x84)
+}
+
+// alawToLinear decodes a G.711 A-law sample.
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+	t := int(a&0x0F) << 4
+	seg := (uint(a) & 0x70) >> 4
+	switch seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= seg - 1
+	}
+	if a&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
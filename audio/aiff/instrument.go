@@ -0,0 +1,110 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// LoopKind identifies one of the two loops of an instrument.
+type LoopKind int
+
+const (
+	// SustainLoop is played while the note is held.
+	SustainLoop LoopKind = iota
+	// ReleaseLoop is played once the note is released.
+	ReleaseLoop
+)
+
+// Loop play modes
+const (
+	NoLooping              int16 = 0
+	ForwardLooping         int16 = 1
+	ForwardBackwardLooping int16 = 2
+)
+
+// Loop is a portion of the sound data delimited by two markers.
+type Loop struct {
+	PlayMode int16
+	// BeginLoop and EndLoop are marker IDs.
+	BeginLoop int16
+	EndLoop   int16
+}
+
+// Instrument contains the sampler information found in the INST chunk.
+type Instrument struct {
+	BaseNote     int8
+	Detune       int8
+	LowNote      int8
+	HighNote     int8
+	LowVelocity  int8
+	HighVelocity int8
+	Gain         int16
+	SustainLoop  Loop
+	ReleaseLoop  Loop
+}
+
+// parseInstChunk reads the INST chunk.
+func (d *Decoder) parseInstChunk(size uint32) error {
+	inst := &Instrument{}
+	if err := binary.Read(d.r, binary.BigEndian, inst); err != nil {
+		return fmt.Errorf("INST chunk failed to parse - %s", err)
+	}
+	d.Instrument = inst
+	return d.jumpTo(int(size) - binary.Size(inst))
+}
+
+// LoopClip returns the section of the sound data covered by the sustain or
+// release loop of the instrument. The loop markers are resolved to sample
+// frames using the MARK chunk.
+func (d *Decoder) LoopClip(which LoopKind) (audio.Clip, error) {
+	if d.Instrument == nil {
+		return nil, errors.New("no INST chunk found")
+	}
+	loop := d.Instrument.SustainLoop
+	if which == ReleaseLoop {
+		loop = d.Instrument.ReleaseLoop
+	}
+	if loop.PlayMode == NoLooping {
+		return nil, errors.New("loop not defined")
+	}
+	begin, ok := d.marker(loop.BeginLoop)
+	if !ok {
+		return nil, fmt.Errorf("loop begin marker %d not found", loop.BeginLoop)
+	}
+	end, ok := d.marker(loop.EndLoop)
+	if !ok {
+		return nil, fmt.Errorf("loop end marker %d not found", loop.EndLoop)
+	}
+	return d.section(int64(begin.Position), int64(end.Position))
+}
+
+// ValidateReferences checks that the markers used by the loops of the INST
+// chunk exist in the MARK chunk, the error listing the dangling references.
+// Loops that aren't played, whose marker IDs are meaningless, are ignored.
+func (d *Decoder) ValidateReferences() error {
+	if d.Instrument == nil {
+		return nil
+	}
+	var missing []string
+	check := func(name string, loop Loop) {
+		if loop.PlayMode == NoLooping {
+			return
+		}
+		if _, ok := d.marker(loop.BeginLoop); !ok {
+			missing = append(missing, fmt.Sprintf("%s loop begin marker %d", name, loop.BeginLoop))
+		}
+		if _, ok := d.marker(loop.EndLoop); !ok {
+			missing = append(missing, fmt.Sprintf("%s loop end marker %d", name, loop.EndLoop))
+		}
+	}
+	check("sustain", d.Instrument.SustainLoop)
+	check("release", d.Instrument.ReleaseLoop)
+	if len(missing) > 0 {
+		return fmt.Errorf("%s - missing %s", ErrUnexpectedData, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// Marker is a position in the sound data, as defined in the MARK chunk.
+type Marker struct {
+	// ID uniquely identifies the marker, it is referenced by other chunks
+	// such as INST.
+	ID int16
+	// Position is the sample frame the marker points to. Markers sit
+	// between frames, position 0 being before the first frame.
+	Position uint32
+	Name     string
+}
+
+// Time returns the position of the marker as a duration at the passed
+// sample rate, 0 if the sample rate is invalid.
+func (m Marker) Time(sampleRate int64) time.Duration {
+	if sampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(int64(m.Position) * int64(time.Second) / sampleRate)
+}
+
+// parseMarkChunk reads the markers of a MARK chunk.
+func (d *Decoder) parseMarkChunk(size uint32) error {
+	var numMarkers uint16
+	if err := binary.Read(d.r, binary.BigEndian, &numMarkers); err != nil {
+		return fmt.Errorf("MARK chunk failed to parse - %s", err)
+	}
+	read := 2
+	d.Markers = make([]Marker, 0, numMarkers)
+	for i := 0; i < int(numMarkers); i++ {
+		var m Marker
+		if err := binary.Read(d.r, binary.BigEndian, &m.ID); err != nil {
+			return fmt.Errorf("marker ID failed to parse - %s", err)
+		}
+		if err := binary.Read(d.r, binary.BigEndian, &m.Position); err != nil {
+			return fmt.Errorf("marker position failed to parse - %s", err)
+		}
+		name, n, err := readPString(d.r)
+		if err != nil {
+			return fmt.Errorf("marker name failed to parse - %s", err)
+		}
+		m.Name = name
+		read += 6 + n
+		d.Markers = append(d.Markers, m)
+	}
+	return d.jumpTo(int(size) - read)
+}
+
+// marker returns the marker with the passed ID.
+func (d *Decoder) marker(id int16) (Marker, bool) {
+	for _, m := range d.Markers {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Marker{}, false
+}
+
+// MarkersByPosition returns a copy of the markers sorted by position.
+// Markers sharing a position keep their MARK chunk order.
+func (d *Decoder) MarkersByPosition() []Marker {
+	markers := make([]Marker, len(d.Markers))
+	copy(markers, d.Markers)
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].Position < markers[j].Position
+	})
+	return markers
+}
+
+// RegionBetweenMarkers returns the section of the sound data between the
+// markers with the passed IDs. The start marker must be positioned before
+// the end marker.
+func (d *Decoder) RegionBetweenMarkers(startID, endID int16) (audio.Clip, error) {
+	start, ok := d.marker(startID)
+	if !ok {
+		return nil, fmt.Errorf("marker %d not found", startID)
+	}
+	end, ok := d.marker(endID)
+	if !ok {
+		return nil, fmt.Errorf("marker %d not found", endID)
+	}
+	if start.Position >= end.Position {
+		return nil, fmt.Errorf("marker %d (frame %d) isn't before marker %d (frame %d)",
+			startID, start.Position, endID, end.Position)
+	}
+	return d.section(int64(start.Position), int64(end.Position))
+}
+
+// MergeMarkers returns the markers sorted by position, the ones found less
+// than withinFrames frames after a kept marker being merged into it. The
+// kept marker is the first of each group, its ID and name are preserved.
+func MergeMarkers(markers []Marker, withinFrames uint32) []Marker {
+	sorted := make([]Marker, len(markers))
+	copy(sorted, markers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Position < sorted[j].Position
+	})
+	var merged []Marker
+	for _, m := range sorted {
+		if n := len(merged); n > 0 && m.Position-merged[n-1].Position < withinFrames {
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
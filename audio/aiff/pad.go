@@ -0,0 +1,98 @@
+package aiff
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// padClip reads its source clip followed by silence.
+type padClip struct {
+	src audio.Clip
+	// size is the size of the padded data
+	size int64
+	// pos is the read position in the padded data
+	pos int64
+}
+
+// PadTo returns a clip of the passed number of frames reading the data of c
+// followed by silence, for instance to align clips before mixing them. Clips
+// already at least that long are returned as is. The silence isn't stored,
+// the returned clip reading c on demand.
+func PadTo(c audio.Clip, frames int64) (audio.Clip, error) {
+	if frames < 0 {
+		return nil, fmt.Errorf("invalid frame count %d", frames)
+	}
+	frameSize := int64(c.FrameInfo().BytesPerFrame())
+	if frameSize == 0 {
+		return nil, ErrFmtNotSupported
+	}
+	size := frames * frameSize
+	if c.Size() >= size {
+		return c, nil
+	}
+	return &padClip{src: c, size: size}, nil
+}
+
+func (c *padClip) Read(p []byte) (n int, err error) {
+	if c.pos >= c.size {
+		return 0, io.EOF
+	}
+	if remaining := c.size - c.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	if srcSize := c.src.Size(); c.pos < srcSize {
+		if _, err := c.src.Seek(c.pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if remaining := srcSize - c.pos; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		n, err = c.src.Read(p)
+		c.pos += int64(n)
+		// a source holding less data than its size would otherwise
+		// never reach the padding
+		if err == io.EOF {
+			err = nil
+			if n == 0 {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+		return n, err
+	}
+	for i := range p {
+		p[i] = 0
+	}
+	c.pos += int64(len(p))
+	return len(p), nil
+}
+
+func (c *padClip) Seek(offset int64, whence int) (int64, error) {
+	abs, err := seekPos(c.pos, c.size, offset, whence, "aiff.PadTo")
+	if err != nil {
+		return 0, err
+	}
+	c.pos = abs
+	return abs, nil
+}
+
+func (c *padClip) FrameInfo() audio.FrameInfo {
+	return c.src.FrameInfo()
+}
+
+func (c *padClip) Channels() int {
+	return c.src.Channels()
+}
+
+func (c *padClip) SampleRate() int64 {
+	return c.src.SampleRate()
+}
+
+func (c *padClip) BitDepth() int {
+	return c.src.BitDepth()
+}
+
+func (c *padClip) Size() int64 {
+	return c.size
+}
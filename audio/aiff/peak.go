@@ -0,0 +1,65 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// PeakPoint is the peak of a channel as stored in the PEAK chunk.
+type PeakPoint struct {
+	// Value is the absolute peak value, normalized to full scale.
+	Value float32
+	// Position is the sample frame where the peak is found.
+	Position uint32
+}
+
+// parsePeakChunk reads the PEAK chunk: a version, a timestamp and a peak
+// point per channel.
+func (d *Decoder) parsePeakChunk(size uint32) error {
+	var header struct {
+		Version   uint32
+		Timestamp uint32
+	}
+	if size < 8 {
+		return fmt.Errorf("%s - PEAK chunk of %d bytes", ErrUnexpectedData, size)
+	}
+	if err := binary.Read(d.r, binary.BigEndian, &header); err != nil {
+		return fmt.Errorf("PEAK chunk failed to parse - %s", err)
+	}
+	peaks := make([]PeakPoint, (size-8)/8)
+	if err := binary.Read(d.r, binary.BigEndian, peaks); err != nil {
+		return fmt.Errorf("PEAK chunk failed to parse - %s", err)
+	}
+	d.PeakInfo = peaks
+	return d.jumpTo(int(size - 8 - uint32(len(peaks))*8))
+}
+
+// checkPeaks scans c and reports an error when the peaks found differ from
+// the ones stored in the PEAK chunk by more than one quantization step.
+func (d *Decoder) checkPeaks(c audio.Clip) error {
+	if len(d.PeakInfo) != c.Channels() {
+		return fmt.Errorf("%s - PEAK chunk has %d channel(s), the clip %d", ErrUnexpectedData, len(d.PeakInfo), c.Channels())
+	}
+	fullScale := float64(maxSample(c.BitDepth()) + 1)
+	peaks := make([]float64, c.Channels())
+	err := eachFrame(c, func(i int64, frame []int) error {
+		for ch, v := range frame {
+			if a := math.Abs(float64(v)) / fullScale; a > peaks[ch] {
+				peaks[ch] = a
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for ch, p := range d.PeakInfo {
+		if math.Abs(float64(p.Value)-peaks[ch]) > 1/fullScale+1e-6 {
+			return fmt.Errorf("%s - PEAK chunk declares %f for channel %d, found %f", ErrUnexpectedData, p.Value, ch, peaks[ch])
+		}
+	}
+	return nil
+}
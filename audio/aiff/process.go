@@ -0,0 +1,54 @@
+package aiff
+
+import (
+	"io"
+)
+
+// Process decodes the AIFF stream read from src, calls fn for each frame
+// and encodes the returned frames as an AIFF file written to dst.
+// The data is processed block by block, without being fully loaded in
+// memory. The frames returned by fn must keep the source channel count.
+// The MIDI data of the source is written to dst too.
+func Process(src io.ReadSeeker, dst io.WriteSeeker, fn func(frame []int) []int) error {
+	d := NewDecoder(src)
+	c, err := d.Decode()
+	if err != nil {
+		return err
+	}
+	// compressed files decode to a wrapper, not a *Clip
+	clip := asClip(c)
+	e := NewEncoder(dst, clip.FrameInfo())
+	if d.MIDI != nil {
+		if err := e.SetMIDI(d.MIDI); err != nil {
+			return err
+		}
+	}
+	frameSize := clip.FrameInfo().BytesPerFrame()
+	if frameSize == 0 {
+		return ErrFmtNotSupported
+	}
+	blockFrames := clip.blockBytes() / frameSize
+	if blockFrames < 1 {
+		blockFrames = 1
+	}
+	block := make([][]int, blockFrames)
+	out := make([][]int, blockFrames)
+	for {
+		n, err := clip.ReadFrames(block)
+		for i, frame := range block[:n] {
+			out[i] = fn(frame)
+		}
+		if n > 0 {
+			if err := e.Write(out[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.Close()
+}
@@ -0,0 +1,143 @@
+package aiff_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+)
+
+// ulawFile returns an AIFC file holding the passed u-law bytes, the passed
+// chunks being written between the COMM and SSND chunks.
+func ulawFile(samples []byte, chunks ...[]byte) []byte {
+	comm := &bytes.Buffer{}
+	binary.Write(comm, binary.BigEndian, uint16(1))
+	binary.Write(comm, binary.BigEndian, uint32(len(samples)))
+	binary.Write(comm, binary.BigEndian, uint16(16))
+	rate := audio.IntToIeeeFloat(8000)
+	comm.Write(rate[:])
+	comm.WriteString("ulaw")
+	comm.Write([]byte{0, 0})
+
+	body := &bytes.Buffer{}
+	body.WriteString("AIFC")
+	aiff.WriteChunk(body, [4]byte{'C', 'O', 'M', 'M'}, comm.Bytes())
+	for _, chunk := range chunks {
+		body.Write(chunk)
+	}
+	aiff.WriteChunk(body, [4]byte{'S', 'S', 'N', 'D'}, append(make([]byte, 8), samples...))
+	b := []byte("FORM\x00\x00\x00\x00")
+	binary.BigEndian.PutUint32(b[4:], uint32(body.Len()))
+	return append(b, body.Bytes()...)
+}
+
+func TestProcessUlaw(t *testing.T) {
+	// 0xff and 0x7f are the two u-law encodings of silence
+	src := bytes.NewReader(ulawFile([]byte{0xff, 0x7f, 0x80, 0x00}))
+	dst, err := os.CreateTemp(t.TempDir(), "*.aif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	var got [][]int
+	err = aiff.Process(src, dst, func(frame []int) []int {
+		got = append(got, append([]int(nil), frame...))
+		return frame
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 frames, got %d", len(got))
+	}
+	if got[0][0] != 0 || got[1][0] != 0 || got[2][0] <= 0 || got[3][0] >= 0 {
+		t.Fatalf("unexpected decoded samples %v", got)
+	}
+}
+
+func TestProcessKeepsMIDI(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	// an odd sized SMF header, followed by a pad byte
+	smf := []byte("MThd\x00\x00\x00\x06\x00\x00\x00\x01\x00\x60!")
+	b := encode(t, info, [][]int{{1}, {2}, {3}}, "")
+	chunk := &bytes.Buffer{}
+	aiff.WriteChunk(chunk, [4]byte{'M', 'I', 'D', 'I'}, smf)
+	b = append(b, chunk.Bytes()...)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	d := aiff.NewDecoder(bytes.NewReader(b))
+	d.Mode = aiff.Strict
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.MIDI, smf) {
+		t.Fatalf("expected MIDI data %q, got %q", smf, d.MIDI)
+	}
+
+	dst, err := os.CreateTemp(t.TempDir(), "*.aif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err := aiff.Process(bytes.NewReader(b), dst, func(frame []int) []int { return frame }); err != nil {
+		t.Fatal(err)
+	}
+	rewritten, err := os.ReadFile(dst.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = aiff.NewDecoder(bytes.NewReader(rewritten))
+	d.Mode = aiff.Strict
+	c, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d.MIDI, smf) {
+		t.Fatalf("expected the rewritten MIDI data %q, got %q", smf, d.MIDI)
+	}
+	if frames, _, err := aiff.ReadAll(c); err != nil || len(frames) != 3 || frames[2][0] != 3 {
+		t.Fatalf("unexpected rewritten frames %v (%v)", frames, err)
+	}
+}
+
+func TestProcessDoubles(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	frames := make([][]int, 5000)
+	for i := range frames {
+		frames[i] = []int{i - 2500, 3 * (2500 - i)}
+	}
+	dst, err := os.CreateTemp(t.TempDir(), "*.aif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	err = aiff.Process(bytes.NewReader(encode(t, info, frames, "")), dst, func(frame []int) []int {
+		return []int{frame[0] * 2, frame[1] * 2}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	c, err := aiff.Decode(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, gotInfo, err := aiff.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotInfo != info || len(got) != len(frames) {
+		t.Fatalf("expected %d frames of %s, got %d frames of %s", len(frames), info, len(got), gotInfo)
+	}
+	for i, frame := range got {
+		if frame[0] != 2*frames[i][0] || frame[1] != 2*frames[i][1] {
+			t.Fatalf("frame %d: expected %v doubled, got %v", i, frames[i], frame)
+		}
+	}
+}
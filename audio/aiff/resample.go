@@ -0,0 +1,315 @@
+package aiff
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// antiAliasTaps is the length of the low-pass filter applied before
+// downsampling.
+const antiAliasTaps = 63
+
+// lanczosSize is the number of lobes on each side of the Lanczos kernel.
+const lanczosSize = 8
+
+// InterpMode is the method used to compute samples between frames.
+type InterpMode int
+
+const (
+	// Linear interpolates between the two surrounding frames.
+	// It is fast but attenuates and aliases high frequencies.
+	Linear InterpMode = iota
+	// Sinc convolves the surrounding frames with a windowed-sinc (Lanczos)
+	// kernel for a higher fidelity at a higher cost.
+	Sinc
+	// Nearest picks the closest frame, without any filtering.
+	Nearest
+)
+
+// ResampleOption changes how Resample converts a clip.
+type ResampleOption int
+
+const (
+	// NoAntiAlias skips the low-pass filter applied before downsampling,
+	// for signals known to be band limited already.
+	NoAntiAlias ResampleOption = iota + 1
+)
+
+// Resample returns an in-memory copy of c converted to the passed sample rate
+// using linear interpolation.
+// When downsampling, the signal is first low-pass filtered at the new Nyquist
+// frequency so content that can't be represented at the target rate doesn't
+// alias, unless the NoAntiAlias option is passed.
+func Resample(c audio.Clip, rate int64, opts ...ResampleOption) (audio.Clip, error) {
+	antiAlias := true
+	for _, opt := range opts {
+		if opt == NoAntiAlias {
+			antiAlias = false
+		}
+	}
+	return resample(c, rate, Linear, antiAlias)
+}
+
+// ResampleQuality is like Resample but lets the caller pick the
+// interpolation. Sinc scales its kernel when downsampling so it doesn't
+// need the separate anti-aliasing filter.
+func ResampleQuality(c audio.Clip, rate int64, mode InterpMode) (audio.Clip, error) {
+	return resample(c, rate, mode, mode != Sinc)
+}
+
+func resample(c audio.Clip, rate int64, mode InterpMode, antiAlias bool) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	srcRate := c.SampleRate()
+	if rate <= 0 || srcRate <= 0 {
+		return nil, fmt.Errorf("%s - can't resample from %dHz to %dHz", ErrFmtNotSupported, srcRate, rate)
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	signal := toFloatFrames(frames)
+	if antiAlias && rate < srcRate {
+		signal = lowPass(signal, float64(rate)/2/float64(srcRate), antiAliasTaps)
+	}
+
+	info := c.FrameInfo()
+	ratio := float64(srcRate) / float64(rate)
+	out := make([][]float64, int64(len(signal))*rate/srcRate)
+	for i := range out {
+		pos := float64(i) * ratio
+		out[i] = make([]float64, info.Channels)
+		switch mode {
+		case Sinc:
+			sincInterp(out[i], signal, pos, math.Min(1, 1/ratio))
+		case Nearest:
+			nearestInterp(out[i], signal, pos)
+		default:
+			linearInterp(out[i], signal, pos)
+		}
+	}
+	info.SampleRate = rate
+	return fromFloatFrames(out, c, info, OverflowClamp)
+}
+
+// nearestInterp sets dst to the frame closest to the fractional position pos
+// of the signal.
+func nearestInterp(dst []float64, signal [][]float64, pos float64) {
+	i := int(pos + 0.5)
+	if i >= len(signal) {
+		i = len(signal) - 1
+	}
+	copy(dst, signal[i])
+}
+
+// linearInterp sets dst to the frame found at the fractional position pos
+// of the signal using linear interpolation.
+func linearInterp(dst []float64, signal [][]float64, pos float64) {
+	i0 := int(pos)
+	i1 := i0 + 1
+	if i1 >= len(signal) {
+		i1 = len(signal) - 1
+	}
+	frac := pos - float64(i0)
+	for ch := range dst {
+		dst[ch] = signal[i0][ch]*(1-frac) + signal[i1][ch]*frac
+	}
+}
+
+// sincInterp sets dst to the frame found at the fractional position pos
+// of the signal using a Lanczos kernel. scale (<= 1) widens the kernel to
+// lower its cutoff frequency when downsampling.
+func sincInterp(dst []float64, signal [][]float64, pos, scale float64) {
+	radius := float64(lanczosSize) / scale
+	first := int(math.Ceil(pos - radius))
+	last := int(math.Floor(pos + radius))
+	for ch := range dst {
+		dst[ch] = 0
+	}
+	var sum float64
+	for j := first; j <= last; j++ {
+		if j < 0 || j >= len(signal) {
+			continue
+		}
+		w := lanczos((pos-float64(j))*scale, lanczosSize)
+		sum += w
+		for ch, v := range signal[j] {
+			dst[ch] += w * v
+		}
+	}
+	if sum != 0 {
+		for ch := range dst {
+			dst[ch] /= sum
+		}
+	}
+}
+
+// ReadFrameInterp returns the samples found at the fractional frame position
+// pos, computed from the surrounding frames with the passed interpolation.
+// As with ReadFrameAt, the read position of the clip isn't changed.
+func (c *Clip) ReadFrameInterp(pos float64, mode InterpMode) ([]int, error) {
+	frames := c.NumFrames()
+	if pos < 0 || pos > float64(frames-1) || math.IsNaN(pos) {
+		return nil, fmt.Errorf("frame position %f out of range 0-%d", pos, frames-1)
+	}
+	// frames needed on each side of pos
+	radius := int64(1)
+	if mode == Sinc {
+		radius = lanczosSize
+	}
+	first := int64(pos) - radius
+	if first < 0 {
+		first = 0
+	}
+	last := int64(pos) + radius + 1
+	if last > frames {
+		last = frames
+	}
+	s, err := c.section(first, last)
+	if err != nil {
+		return nil, err
+	}
+	window, err := readAllFrames(s)
+	if err != nil {
+		return nil, err
+	}
+	signal := toFloatFrames(window)
+	out := make([]float64, c.channels)
+	local := pos - float64(first)
+	switch mode {
+	case Nearest:
+		nearestInterp(out, signal, local)
+	case Sinc:
+		sincInterp(out, signal, local, 1)
+	default:
+		linearInterp(out, signal, local)
+	}
+	return toIntFrames([][]float64{out}, c.bitDepth)[0], nil
+}
+
+// lanczos returns the value of the Lanczos kernel of the passed size at x.
+func lanczos(x float64, size int) float64 {
+	if x == 0 {
+		return 1
+	}
+	a := float64(size)
+	if x <= -a || x >= a {
+		return 0
+	}
+	px := math.Pi * x
+	return a * math.Sin(px) * math.Sin(px/a) / (px * px)
+}
+
+// lowPass applies a windowed-sinc FIR low-pass filter to each channel of the
+// signal. The cutoff frequency is expressed as a fraction of the sample rate.
+func lowPass(signal [][]float64, cutoff float64, taps int) [][]float64 {
+	kernel := lowPassKernel(cutoff, taps, 1)
+	half := taps / 2
+	out := make([][]float64, len(signal))
+	for i := range signal {
+		out[i] = make([]float64, len(signal[i]))
+		for k, h := range kernel {
+			j := i + k - half
+			if j < 0 || j >= len(signal) {
+				continue
+			}
+			for ch, v := range signal[j] {
+				out[i][ch] += h * v
+			}
+		}
+	}
+	return out
+}
+
+// lowPassKernel returns the coefficients of a Blackman windowed-sinc
+// low-pass filter, the cutoff frequency being a fraction of the sample
+// rate. The coefficients are normalized to sum to gain.
+func lowPassKernel(cutoff float64, taps int, gain float64) []float64 {
+	kernel := make([]float64, taps)
+	m := float64(taps - 1)
+	var sum float64
+	for i := range kernel {
+		x := float64(i) - m/2
+		v := 2 * cutoff
+		if x != 0 {
+			v = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		// Blackman window
+		v *= 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/m) + 0.08*math.Cos(4*math.Pi*float64(i)/m)
+		kernel[i] = v
+		sum += v
+	}
+	for i := range kernel {
+		kernel[i] *= gain / sum
+	}
+	return kernel
+}
+
+// ResampleRatio returns an in-memory copy of c resampled by the rational
+// ratio num/den, for instance 147/160 to convert 48kHz to 44.1kHz. The
+// signal is upsampled by num, low-pass filtered and downsampled by den,
+// which is exact for such ratios, contrary to Resample's fractional
+// positions. The resulting sample rate has to be an integer.
+func ResampleRatio(c audio.Clip, num, den int) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	if num < 1 || den < 1 {
+		return nil, fmt.Errorf("invalid resampling ratio %d/%d", num, den)
+	}
+	srcRate := c.SampleRate()
+	if srcRate*int64(num)%int64(den) != 0 {
+		return nil, fmt.Errorf("%s - %dHz * %d/%d isn't an integer sample rate", ErrFmtNotSupported, srcRate, num, den)
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	signal := toFloatFrames(frames)
+
+	g := gcd(num, den)
+	up, down := num/g, den/g
+	// the filter spans zeroCrossings input frames on each side, at the
+	// lowest of the two Nyquist frequencies in the upsampled domain.
+	const zeroCrossings = 16
+	factor := up
+	if down > factor {
+		factor = down
+	}
+	half := zeroCrossings * factor
+	kernel := lowPassKernel(0.5/float64(factor), 2*half+1, float64(up))
+
+	info := c.FrameInfo()
+	out := make([][]float64, int64(len(signal))*int64(up)/int64(down))
+	for n := range out {
+		out[n] = make([]float64, info.Channels)
+		// position in the upsampled signal, only every up-th sample
+		// of which is non zero
+		t := n * down
+		first := t - half
+		// first upsampled index >= first that maps to an input frame
+		j := (first + up - 1) / up
+		if first < 0 {
+			j = 0
+		}
+		for ; j*up <= t+half && j < len(signal); j++ {
+			h := kernel[j*up-first]
+			for ch, v := range signal[j] {
+				out[n][ch] += h * v
+			}
+		}
+	}
+	info.SampleRate = srcRate * int64(num) / int64(den)
+	return fromFloatFrames(out, c, info, OverflowClamp)
+}
+
+// gcd returns the greatest common divisor of a and b.
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
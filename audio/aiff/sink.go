@@ -0,0 +1,55 @@
+package aiff
+
+import (
+	"io"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// Sink receives decoded frames, each frame holding one sample per channel.
+// It is the integration point of audio backends: Play pushes the frames of
+// a clip to a sink without the package depending on any of them.
+// The frames are reused once Write returns and must be copied to be kept.
+type Sink interface {
+	Write(frames [][]int) error
+}
+
+// NullSink is a Sink discarding the frames, for instance to measure the
+// decoding speed.
+type NullSink struct{}
+
+// Write discards the frames.
+func (NullSink) Write(frames [][]int) error {
+	return nil
+}
+
+// Play reads c from its current position and writes its frames to s in
+// blocks of blockFrames frames, in order, until the end of the clip.
+func Play(c audio.Clip, s Sink, blockFrames int) error {
+	if blockFrames < 1 {
+		blockFrames = 1
+	}
+	pos, err := c.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	clip := asClip(c)
+	if _, err := clip.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	block := make([][]int, blockFrames)
+	for {
+		n, err := clip.ReadFrames(block)
+		if n > 0 {
+			if err := s.Write(block[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package aiff
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// aiffStream presents a clip as a complete AIFF file.
+type aiffStream struct {
+	header []byte
+	c      audio.Clip
+	// dataSize is the amount of sound data, excluding the pad byte.
+	dataSize int64
+	pos      int64
+}
+
+// AIFFStream returns an AIFF stream made of a synthesized FORM/COMM/SSND
+// header followed by the PCM data of c, for code expecting an AIFF file
+// rather than a clip. The data of c is read lazily, as the stream is read.
+func AIFFStream(c audio.Clip) io.ReadSeeker {
+	e := &Encoder{info: c.FrameInfo()}
+	if frameSize := int64(e.info.BytesPerFrame()); frameSize > 0 {
+		e.frames = c.Size() / frameSize
+	}
+	header := &bytes.Buffer{}
+	e.writeHeader(header)
+	return &aiffStream{header: header.Bytes(), c: c, dataSize: e.dataSize()}
+}
+
+// size returns the total size of the stream.
+func (s *aiffStream) size() int64 {
+	return int64(len(s.header)) + s.dataSize + s.dataSize%2
+}
+
+func (s *aiffStream) Read(p []byte) (n int, err error) {
+	if s.pos >= s.size() {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	headerSize := int64(len(s.header))
+	if s.pos < headerSize {
+		n = copy(p, s.header[s.pos:])
+		s.pos += int64(n)
+		return n, nil
+	}
+	dataPos := s.pos - headerSize
+	if dataPos >= s.dataSize {
+		// pad byte
+		p[0] = 0
+		s.pos++
+		return 1, nil
+	}
+	if _, err := s.c.Seek(dataPos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if remaining := s.dataSize - dataPos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err = s.c.Read(p)
+	s.pos += int64(n)
+	if err == io.EOF {
+		err = nil
+		if n == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	return n, err
+}
+
+func (s *aiffStream) Seek(offset int64, whence int) (int64, error) {
+	abs, err := seekPos(s.pos, s.size(), offset, whence, "aiff.aiffStream.Seek")
+	if err != nil {
+		return 0, err
+	}
+	s.pos = abs
+	return abs, nil
+}
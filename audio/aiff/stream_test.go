@@ -0,0 +1,43 @@
+package aiff
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+)
+
+func TestAIFFStreamPadByte(t *testing.T) {
+	// a single 8-bit sample needs a pad byte
+	s := AIFFStream(newMemClip([][]int{{1}}, audio.FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}))
+	size, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Seek(size-1, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Read(nil); n != 0 || err != nil {
+		t.Fatalf("empty read at the pad byte returned %d, %v", n, err)
+	}
+
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(b)) != size || size%2 != 0 {
+		t.Fatalf("expected an even stream of %d bytes, got %d", size, len(b))
+	}
+	c, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size() != 1 {
+		t.Fatalf("expected 1 byte of sound data, got %d", c.Size())
+	}
+}
@@ -0,0 +1,96 @@
+package aiff
+
+import (
+	"io"
+	"sync"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// teeSource is the clip shared by the views returned by Tee.
+type teeSource struct {
+	mu sync.Mutex
+	c  audio.Clip
+	// buf holds the data read from c that one of the views didn't read
+	// yet, start being the position of its first byte.
+	buf   []byte
+	start int64
+	// pos holds the read position of each view.
+	pos [2]int64
+}
+
+// teeClip is a view over a shared clip with its own read position.
+type teeClip struct {
+	audio.Clip
+	src *teeSource
+	// view is the index of the view in src.pos.
+	view int
+}
+
+// Tee returns two clips reading the data of c independently, each with its
+// own read position starting at the current position of c. Like
+// io.TeeReader, c is read once: the data one view reads ahead of the other
+// is buffered until the other view reads it, so the memory used grows with
+// the distance between the views. c is only seeked when a view is moved
+// away from the buffered data. The views can be used from different
+// goroutines, for instance to meter a clip while it's being played.
+// c shouldn't be read directly while the views are in use.
+func Tee(c audio.Clip) (audio.Clip, audio.Clip) {
+	pos, _ := c.Seek(0, io.SeekCurrent)
+	src := &teeSource{c: c, start: pos, pos: [2]int64{pos, pos}}
+	return &teeClip{Clip: c, src: src, view: 0}, &teeClip{Clip: c, src: src, view: 1}
+}
+
+func (t *teeClip) Read(p []byte) (n int, err error) {
+	s := t.src
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pos := s.pos[t.view]
+	end := s.start + int64(len(s.buf))
+	if pos < s.start || pos > end {
+		// the view was moved away from the buffered data
+		if _, err := s.c.Seek(pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+		s.buf, s.start, end = s.buf[:0], pos, pos
+	}
+	if pos == end {
+		n, err = s.c.Read(p)
+		s.buf = append(s.buf, p[:n]...)
+	} else {
+		n = copy(p, s.buf[pos-s.start:])
+	}
+	s.pos[t.view] += int64(n)
+	s.trim()
+	return n, err
+}
+
+// trim drops the buffered data both views have read.
+func (s *teeSource) trim() {
+	low := s.pos[0]
+	if s.pos[1] < low {
+		low = s.pos[1]
+	}
+	drop := low - s.start
+	if drop <= 0 {
+		return
+	}
+	if drop > int64(len(s.buf)) {
+		drop = int64(len(s.buf))
+	}
+	n := copy(s.buf, s.buf[drop:])
+	s.buf = s.buf[:n]
+	s.start += drop
+}
+
+func (t *teeClip) Seek(offset int64, whence int) (int64, error) {
+	s := t.src
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	abs, err := seekPos(s.pos[t.view], t.Size(), offset, whence, "aiff.Tee")
+	if err != nil {
+		return 0, err
+	}
+	s.pos[t.view] = abs
+	return abs, nil
+}
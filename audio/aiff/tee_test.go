@@ -0,0 +1,79 @@
+package aiff_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+)
+
+// streamClip is a clip that can't seek, like one reading from a pipe.
+type streamClip struct {
+	audio.Clip
+	pos int64
+}
+
+func (s *streamClip) Read(p []byte) (int, error) {
+	n, err := s.Clip.Read(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *streamClip) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekCurrent {
+		return s.pos, nil
+	}
+	return 0, errors.New("streamClip: can't seek")
+}
+
+func TestTee(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	src := &streamClip{Clip: aiff.RawClip(bytes.NewReader(data), info, int64(len(data)))}
+	a, b := aiff.Tee(src)
+
+	// read in lockstep, with different sizes so each view is ahead in
+	// turn
+	var gotA, gotB []byte
+	pa, pb := make([]byte, 12), make([]byte, 20)
+	for len(gotA) < len(data) || len(gotB) < len(data) {
+		n, errA := a.Read(pa)
+		gotA = append(gotA, pa[:n]...)
+		m, errB := b.Read(pb)
+		gotB = append(gotB, pb[:m]...)
+		if errA != nil && errA != io.EOF || errB != nil && errB != io.EOF {
+			t.Fatal(errA, errB)
+		}
+		if n == 0 && m == 0 {
+			break
+		}
+	}
+	if !bytes.Equal(gotA, data) || !bytes.Equal(gotB, data) {
+		t.Fatalf("expected both views to read the %d bytes of the source, got %d and %d", len(data), len(gotA), len(gotB))
+	}
+}
+
+func TestTeeSeek(t *testing.T) {
+	data := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	info := audio.FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}
+	a, b := aiff.Tee(aiff.RawClip(bytes.NewReader(data), info, int64(len(data))))
+	p := make([]byte, 4)
+	if _, err := io.ReadFull(a, p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := b.Read(p); !bytes.Equal(p[:n], []byte{6, 7}) {
+		t.Fatalf("expected the data at the seek position, got %v", p[:n])
+	}
+	if _, err := io.ReadFull(a, p); err != nil || !bytes.Equal(p, []byte{4, 5, 6, 7}) {
+		t.Fatalf("expected the end of the data, got %v (%v)", p, err)
+	}
+}
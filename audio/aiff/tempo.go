@@ -0,0 +1,36 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// bascVersion is the only known version of the basc chunk layout.
+const bascVersion = 1
+
+// parseBascChunk reads the basc chunk written by Apple Loops tools: a
+// version, the number of beats of the loop, then the key, the time
+// signature and the loop type which aren't exposed. Chunks with another
+// layout are kept raw.
+func (d *Decoder) parseBascChunk(size uint32) error {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return fmt.Errorf("basc chunk failed to parse - %s", err)
+	}
+	if size < 8 || binary.BigEndian.Uint32(data) != bascVersion {
+		d.Basc = data
+		return nil
+	}
+	d.Beats = int(binary.BigEndian.Uint32(data[4:]))
+	return nil
+}
+
+// setTempo derives the tempo from the number of beats and the duration of
+// the file, both being needed.
+func (d *Decoder) setTempo() {
+	if d.Beats <= 0 || d.NumSampleFrames == 0 || d.SampleRate <= 0 {
+		return
+	}
+	d.Tempo = float64(d.Beats) * 60 * float64(d.SampleRate) / float64(d.NumSampleFrames)
+}
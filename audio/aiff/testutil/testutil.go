@@ -0,0 +1,160 @@
+// Package testutil generates deterministic AIFF files to test the parsing
+// features of the aiff package against.
+package testutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+)
+
+// TestOpts lists the optional content of a generated file.
+type TestOpts struct {
+	// Frames is the number of sample frames to generate, each channel
+	// holding a ramp. Ignored when Samples is set.
+	Frames int
+	// Samples are the frames to write instead of the generated ramp.
+	Samples [][]int
+	// Markers are written in a MARK chunk.
+	Markers []aiff.Marker
+	// Instrument is written in an INST chunk.
+	Instrument *aiff.Instrument
+	// Sowt writes an AIFC file with little endian 'sowt' samples.
+	Sowt bool
+	// Name, Author and Annotation are written as NAME, AUTH and ANNO text
+	// chunks when not empty. Odd length texts get a pad byte.
+	Name       string
+	Author     string
+	Annotation string
+}
+
+// aifcVersion is the timestamp identifying the AIFC spec version.
+const aifcVersion = 0xA2805140
+
+// WriteTestAIFF writes an AIFF file with the passed format and content to w.
+func WriteTestAIFF(w io.WriteSeeker, info audio.FrameInfo, opts TestOpts) error {
+	frames := opts.Samples
+	if frames == nil {
+		frames = Ramp(info, opts.Frames)
+	}
+
+	body := &bytes.Buffer{}
+	formType := "AIFF"
+	if opts.Sowt {
+		formType = "AIFC"
+		fver := make([]byte, 4)
+		binary.BigEndian.PutUint32(fver, aifcVersion)
+		aiff.WriteChunk(body, id("FVER"), fver)
+	}
+	aiff.WriteChunk(body, id("COMM"), comm(info, len(frames), opts.Sowt))
+	if opts.Markers != nil {
+		aiff.WriteChunk(body, id("MARK"), mark(opts.Markers))
+	}
+	if opts.Instrument != nil {
+		inst := &bytes.Buffer{}
+		binary.Write(inst, binary.BigEndian, opts.Instrument)
+		aiff.WriteChunk(body, id("INST"), inst.Bytes())
+	}
+	for _, text := range []struct {
+		id, text string
+	}{{"NAME", opts.Name}, {"AUTH", opts.Author}, {"ANNO", opts.Annotation}} {
+		if text.text != "" {
+			aiff.WriteChunk(body, id(text.id), []byte(text.text))
+		}
+	}
+	aiff.WriteChunk(body, id("SSND"), ssnd(info, frames, opts.Sowt))
+
+	header := make([]byte, 12)
+	copy(header, "FORM")
+	binary.BigEndian.PutUint32(header[4:], uint32(4+body.Len()))
+	copy(header[8:], formType)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := body.WriteTo(w)
+	return err
+}
+
+// Ramp returns n frames where each channel ramps up through the values of
+// the bit depth, channels being offset from each other.
+func Ramp(info audio.FrameInfo, n int) [][]int {
+	frames := make([][]int, n)
+	for i := range frames {
+		frames[i] = make([]int, info.Channels)
+		for ch := range frames[i] {
+			v := (i+ch*16)%256 - 128
+			if info.BitDepth >= 8 {
+				v <<= uint(info.BitDepth - 8)
+			} else {
+				v >>= uint(8 - info.BitDepth)
+			}
+			frames[i][ch] = v
+		}
+	}
+	return frames
+}
+
+func id(s string) [4]byte {
+	var b [4]byte
+	copy(b[:], s)
+	return b
+}
+
+func comm(info audio.FrameInfo, frames int, sowt bool) []byte {
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.BigEndian, uint16(info.Channels))
+	binary.Write(b, binary.BigEndian, uint32(frames))
+	binary.Write(b, binary.BigEndian, uint16(info.BitDepth))
+	rate := audio.IntToIeeeFloat(int(info.SampleRate))
+	b.Write(rate[:])
+	if sowt {
+		b.WriteString("sowt")
+		writePString(b, "")
+	}
+	return b.Bytes()
+}
+
+func mark(markers []aiff.Marker) []byte {
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.BigEndian, uint16(len(markers)))
+	for _, m := range markers {
+		binary.Write(b, binary.BigEndian, m.ID)
+		binary.Write(b, binary.BigEndian, m.Position)
+		writePString(b, m.Name)
+	}
+	return b.Bytes()
+}
+
+// writePString writes a pascal style string padded to an even length.
+func writePString(b *bytes.Buffer, s string) {
+	b.WriteByte(byte(len(s)))
+	b.WriteString(s)
+	if (len(s)+1)%2 == 1 {
+		b.WriteByte(0)
+	}
+}
+
+func ssnd(info audio.FrameInfo, frames [][]int, sowt bool) []byte {
+	sampleSize := (info.BitDepth + 7) / 8
+	shift := uint(sampleSize*8 - info.BitDepth)
+	// offset and block size
+	b := make([]byte, 8, 8+len(frames)*info.Channels*sampleSize)
+	sample := make([]byte, sampleSize)
+	for _, frame := range frames {
+		for _, v := range frame {
+			u := uint32(v << shift)
+			for i := range sample {
+				if sowt {
+					sample[i] = byte(u >> (8 * uint(i)))
+				} else {
+					sample[sampleSize-1-i] = byte(u >> (8 * uint(i)))
+				}
+			}
+			b = append(b, sample...)
+		}
+	}
+	return b
+}
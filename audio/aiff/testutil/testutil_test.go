@@ -0,0 +1,76 @@
+package testutil
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+)
+
+func TestWriteTestAIFF(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 48000}
+	markers := []aiff.Marker{{ID: 1, Position: 0, Name: "start"}, {ID: 2, Position: 10, Name: "ab"}}
+	inst := &aiff.Instrument{BaseNote: 60, HighNote: 127, HighVelocity: 127,
+		SustainLoop: aiff.Loop{PlayMode: aiff.ForwardLooping, BeginLoop: 1, EndLoop: 2}}
+
+	tests := []struct {
+		name string
+		opts TestOpts
+	}{
+		{"plain", TestOpts{Frames: 20}},
+		{"markers", TestOpts{Frames: 20, Markers: markers}},
+		{"instrument", TestOpts{Frames: 20, Markers: markers, Instrument: inst}},
+		{"sowt", TestOpts{Frames: 20, Sowt: true}},
+		{"odd text chunks", TestOpts{Frames: 20, Name: "odd", Author: "even", Annotation: "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.CreateTemp(t.TempDir(), "*.aif")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := WriteTestAIFF(f, info, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := aiff.NewDecoder(bytes.NewReader(b))
+			d.Mode = aiff.Strict
+			c, err := d.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			frames, got, err := aiff.ReadAll(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != info {
+				t.Fatalf("expected %s, got %s", info, got)
+			}
+			if expected := Ramp(info, tt.opts.Frames); !reflect.DeepEqual(frames, expected) {
+				t.Fatalf("expected frames %v, got %v", expected[:2], frames[:2])
+			}
+			if !reflect.DeepEqual(d.Markers, tt.opts.Markers) && (len(d.Markers) != 0 || tt.opts.Markers != nil) {
+				t.Fatalf("expected markers %+v, got %+v", tt.opts.Markers, d.Markers)
+			}
+			if tt.opts.Instrument != nil {
+				if d.Instrument == nil || *d.Instrument != *tt.opts.Instrument {
+					t.Fatalf("expected instrument %+v, got %+v", tt.opts.Instrument, d.Instrument)
+				}
+				if err := d.ValidateReferences(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(d.Warnings) != 0 {
+				t.Fatalf("unexpected warnings %q", d.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+package aiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mattetti/exp/audio"
+)
+
+// NormalizeBatch applies the same gain to all the passed clips so the loudest
+// sample across all of them reaches targetDB (in dBFS). The relative levels
+// between the clips are preserved. The returned clips are in-memory copies.
+// A target above 0 dBFS makes samples exceed the range of their bit depth,
+// they are then handled following the overflow policy.
+func NormalizeBatch(clips []audio.Clip, targetDB float64, overflow Overflow) ([]audio.Clip, error) {
+	all := make([][][]int, len(clips))
+	var peak float64
+	for i, c := range clips {
+		if err := checkSupported(c.FrameInfo()); err != nil {
+			return nil, err
+		}
+		frames, err := readAllFrames(c)
+		if err != nil {
+			return nil, err
+		}
+		all[i] = frames
+		fullScale := float64(maxSample(c.BitDepth()) + 1)
+		for _, frame := range frames {
+			for _, v := range frame {
+				if a := math.Abs(float64(v)) / fullScale; a > peak {
+					peak = a
+				}
+			}
+		}
+	}
+
+	gain := 1.0
+	if peak > 0 {
+		gain = audio.DBToAmplitude(targetDB) / peak
+	}
+	out := make([]audio.Clip, len(clips))
+	for i, frames := range all {
+		c, err := fromFloatFrames(applyGain(frames, gain), clips[i], clips[i].FrameInfo(), overflow)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = c
+	}
+	return out, nil
+}
+
+// Gain returns an in-memory copy of c amplified by the passed gain in dB.
+// Samples exceeding the range of the bit depth are handled following the
+// overflow policy.
+func Gain(c audio.Clip, db float64, overflow Overflow) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	return fromFloatFrames(applyGain(frames, audio.DBToAmplitude(db)), c, c.FrameInfo(), overflow)
+}
+
+// applyGain returns the samples multiplied by gain as a float signal.
+func applyGain(frames [][]int, gain float64) [][]float64 {
+	signal := toFloatFrames(frames)
+	for _, frame := range signal {
+		for j := range frame {
+			frame[j] *= gain
+		}
+	}
+	return signal
+}
+
+// Int16Mono downmixes the clip to mono, resamples it to targetRate and
+// returns its samples as int16 values, peak-normalized to full scale.
+// It is meant as a one-call preprocessor for pipelines expecting a fixed
+// input format.
+func (c *Clip) Int16Mono(targetRate int64) ([]int16, error) {
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	mono := make([][]int, len(frames))
+	for i, frame := range frames {
+		var sum int
+		for _, v := range frame {
+			sum += v
+		}
+		mono[i] = []int{sum / len(frame)}
+	}
+	info := c.FrameInfo()
+	info.Channels = 1
+	resampled, err := Resample(newMemClip(mono, info), targetRate)
+	if err != nil {
+		return nil, err
+	}
+	if mono, err = readAllFrames(resampled); err != nil {
+		return nil, err
+	}
+
+	var peak int
+	for _, frame := range mono {
+		if v := frame[0]; v > peak {
+			peak = v
+		} else if -v > peak {
+			peak = -v
+		}
+	}
+	out := make([]int16, len(mono))
+	if peak == 0 {
+		return out, nil
+	}
+	gain := float64(math.MaxInt16) / float64(peak)
+	for i, frame := range mono {
+		out[i] = int16(math.Floor(float64(frame[0])*gain + 0.5))
+	}
+	return out, nil
+}
+
+// Pan returns an in-memory copy of the stereo clip c panned to the passed
+// position, from -1 (hard left) to 1 (hard right), using the equal-power
+// panning law. The gains are scaled so the center position leaves the
+// signal unchanged.
+func Pan(c audio.Clip, position float64) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	if c.Channels() != 2 {
+		return nil, fmt.Errorf("%s - can only pan stereo clips, not %d channel(s)", ErrFmtNotSupported, c.Channels())
+	}
+	if position < -1 || position > 1 {
+		return nil, fmt.Errorf("pan position %f out of the -1..1 range", position)
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	angle := (position + 1) * math.Pi / 4
+	left, right := math.Sqrt2*math.Cos(angle), math.Sqrt2*math.Sin(angle)
+	signal := toFloatFrames(frames)
+	for _, frame := range signal {
+		frame[0] *= left
+		frame[1] *= right
+	}
+	return fromFloatFrames(signal, c, c.FrameInfo(), OverflowClamp)
+}
+
+// InvertPhase returns an in-memory copy of c with the samples of the passed
+// channels negated, all channels being inverted if none are passed.
+// The most negative value, which has no positive counterpart, is clamped
+// to the largest positive one instead of wrapping around.
+func InvertPhase(c audio.Clip, channels ...int) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	invert := make([]bool, c.Channels())
+	for _, ch := range channels {
+		if ch < 0 || ch >= len(invert) {
+			return nil, fmt.Errorf("channel %d out of range, the clip has %d channel(s)", ch, len(invert))
+		}
+		invert[ch] = true
+	}
+	if len(channels) == 0 {
+		for ch := range invert {
+			invert[ch] = true
+		}
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	bitDepth := c.BitDepth()
+	for _, frame := range frames {
+		for ch, v := range frame {
+			if invert[ch] {
+				frame[ch] = clamp(-v, bitDepth)
+			}
+		}
+	}
+	return newMemClip(frames, c.FrameInfo()), nil
+}
+
+// SwapChannels returns an in-memory copy of c with the samples of channels a
+// and b exchanged, for instance to fix a recording with left and right
+// wired the wrong way around.
+func SwapChannels(c audio.Clip, a, b int) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	for _, ch := range []int{a, b} {
+		if ch < 0 || ch >= c.Channels() {
+			return nil, fmt.Errorf("channel %d out of range, the clip has %d channel(s)", ch, c.Channels())
+		}
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, frame := range frames {
+		frame[a], frame[b] = frame[b], frame[a]
+	}
+	return newMemClip(frames, c.FrameInfo()), nil
+}
+
+// ChannelGain returns an in-memory copy of c with each channel amplified by
+// its own gain in dB, gainsDB holding one value per channel. Samples
+// exceeding the range of the bit depth are clamped.
+func ChannelGain(c audio.Clip, gainsDB []float64) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	if len(gainsDB) != c.Channels() {
+		return nil, fmt.Errorf("%d gain(s) passed for %d channel(s)", len(gainsDB), c.Channels())
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	gains := make([]float64, len(gainsDB))
+	for ch, db := range gainsDB {
+		gains[ch] = audio.DBToAmplitude(db)
+	}
+	signal := toFloatFrames(frames)
+	for _, frame := range signal {
+		for ch := range frame {
+			frame[ch] *= gains[ch]
+		}
+	}
+	return fromFloatFrames(signal, c, c.FrameInfo(), OverflowClamp)
+}
+
+// DownmixMatrix returns an in-memory copy of c mixed to len(matrix) channels,
+// matrix[out][in] being the coefficient applied to the input channel in when
+// summed into the output channel out. For instance a 5.1 (L, R, C, LFE, Ls,
+// Rs) to stereo downmix uses:
+//
+//	[][]float64{
+//		{1, 0, 0.707, 0, 0.707, 0},
+//		{0, 1, 0.707, 0, 0, 0.707},
+//	}
+//
+// Samples exceeding the range of the bit depth are clamped.
+func DownmixMatrix(c audio.Clip, matrix [][]float64) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	if len(matrix) == 0 {
+		return nil, errors.New("empty downmix matrix")
+	}
+	for out, row := range matrix {
+		if len(row) != c.Channels() {
+			return nil, fmt.Errorf("downmix matrix row %d has %d coefficient(s), the clip has %d channel(s)", out, len(row), c.Channels())
+		}
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	signal := make([][]float64, len(frames))
+	for i, frame := range frames {
+		signal[i] = make([]float64, len(matrix))
+		for out, row := range matrix {
+			for in, v := range frame {
+				signal[i][out] += row[in] * float64(v)
+			}
+		}
+	}
+	info := c.FrameInfo()
+	info.Channels = len(matrix)
+	return fromFloatFrames(signal, c, info, OverflowClamp)
+}
+
+// StereoWidth returns an in-memory copy of the stereo clip c with its stereo
+// image scaled by width: the side (L-R) component is multiplied by width
+// while the mid (L+R) one is kept. A width of 0 collapses the clip to mono,
+// 1 leaves it unchanged and values above 1 widen it. Samples exceeding the
+// range of the bit depth are clamped.
+func StereoWidth(c audio.Clip, width float64) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	if c.Channels() != 2 {
+		return nil, fmt.Errorf("%s - can only change the width of stereo clips, not %d channel(s)", ErrFmtNotSupported, c.Channels())
+	}
+	if width < 0 {
+		return nil, fmt.Errorf("invalid stereo width %f", width)
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	signal := toFloatFrames(frames)
+	for _, frame := range signal {
+		mid := (frame[0] + frame[1]) / 2
+		side := (frame[0] - frame[1]) / 2 * width
+		frame[0], frame[1] = mid+side, mid-side
+	}
+	return fromFloatFrames(signal, c, c.FrameInfo(), OverflowClamp)
+}
+
+// stretchWindow is the duration of the segments overlapped by TimeStretch.
+const stretchWindow = 40 * time.Millisecond
+
+// TimeStretch returns an in-memory copy of c lasting factor times its
+// duration without changing its pitch, factor > 1 slowing it down.
+// It uses WSOLA: Hann windowed segments of the source are overlap-added at a
+// fixed output hop, each segment being picked around its nominal position
+// where it best continues the previous one to avoid phase cancellations.
+func TimeStretch(c audio.Clip, factor float64) (audio.Clip, error) {
+	if err := checkSupported(c.FrameInfo()); err != nil {
+		return nil, err
+	}
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return nil, fmt.Errorf("invalid stretch factor %f", factor)
+	}
+	frames, err := readAllFrames(c)
+	if err != nil {
+		return nil, err
+	}
+	signal := toFloatFrames(frames)
+	info := c.FrameInfo()
+
+	window := int(info.SampleRate*int64(stretchWindow)/int64(time.Second)) &^ 1
+	if window < 16 {
+		window = 16
+	}
+	hop := window / 2
+	tolerance := window / 8
+	hann := make([]float64, window)
+	for i := range hann {
+		hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(window))
+	}
+	// the segments are matched on the downmixed signal
+	mono := make([]float64, len(signal))
+	for i, frame := range signal {
+		for _, v := range frame {
+			mono[i] += v
+		}
+	}
+
+	outLen := int(float64(len(signal)) * factor)
+	out := make([][]float64, outLen+window)
+	for i := range out {
+		out[i] = make([]float64, info.Channels)
+	}
+	// sum of the windows applied to each output frame, the segments
+	// cut short by the end of the source don't add up to 1.
+	weights := make([]float64, len(out))
+	var prev int
+	for k := 0; k*hop < outLen; k++ {
+		pos := int(float64(k*hop) / factor)
+		if k > 0 {
+			pos = bestOverlap(mono, prev+hop, pos, tolerance, hop)
+		}
+		// the last segments are moved back to fit in the source,
+		// a cut segment would leave the end of the output uncovered.
+		if last := len(signal) - window; pos > last && last >= 0 {
+			pos = last
+		}
+		for i, w := range hann {
+			j := pos + i
+			if j >= len(signal) {
+				break
+			}
+			// nothing overlaps the first half of the first segment
+			if k == 0 && i < hop {
+				w = 1
+			}
+			for ch, v := range signal[j] {
+				out[k*hop+i][ch] += w * v
+			}
+			weights[k*hop+i] += w
+		}
+		prev = pos
+	}
+	for i, frame := range out[:outLen] {
+		if weights[i] < 1e-9 {
+			continue
+		}
+		for ch := range frame {
+			frame[ch] /= weights[i]
+		}
+	}
+	return fromFloatFrames(out[:outLen], c, info, OverflowClamp)
+}
+
+// bestOverlap returns the position, within tolerance frames of nominal,
+// whose next length frames correlate the most with the ones following
+// natural.
+func bestOverlap(signal []float64, natural, nominal, tolerance, length int) int {
+	best, bestCorr := nominal, math.Inf(-1)
+	for pos := nominal - tolerance; pos <= nominal+tolerance; pos++ {
+		if pos < 0 || pos >= len(signal) {
+			continue
+		}
+		var corr float64
+		for i := 0; i < length; i++ {
+			a, b := natural+i, pos+i
+			if a >= len(signal) || b >= len(signal) {
+				break
+			}
+			corr += signal[a] * signal[b]
+		}
+		if corr > bestCorr {
+			best, bestCorr = pos, corr
+		}
+	}
+	return best
+}
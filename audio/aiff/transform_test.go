@@ -0,0 +1,473 @@
+package aiff
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+)
+
+func TestTimeStretchKeepsLevel(t *testing.T) {
+	frames := make([][]int, 48000)
+	for i := range frames {
+		frames[i] = []int{10000}
+	}
+	for _, factor := range []float64{0.5, 1.5, 2} {
+		c, err := TimeStretch(newMemClip(frames, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 48000}), factor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := int(float64(len(frames)) * factor); len(out) != expected {
+			t.Fatalf("x%v: expected %d frames, got %d", factor, expected, len(out))
+		}
+		for i, frame := range out {
+			if frame[0] < 9900 || frame[0] > 10100 {
+				t.Fatalf("x%v: frame %d/%d has level %d, expected 10000", factor, i, len(out), frame[0])
+			}
+		}
+	}
+}
+
+func TestOutputBitDepth(t *testing.T) {
+	c := newMemClip([][]int{{1 << 20}, {-3 << 16}}, audio.FrameInfo{Channels: 1, BitDepth: 24, SampleRate: 8000})
+	g, err := Gain(c, 0, OverflowClamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.BitDepth() != 24 {
+		t.Fatalf("expected Gain to keep 24 bits, got %d", g.BitDepth())
+	}
+
+	for _, depth := range []int{-1, 33, 64} {
+		if err := c.SetOutputBitDepth(depth); err == nil {
+			t.Fatalf("expected an error setting an output depth of %d", depth)
+		}
+	}
+	if err := c.SetOutputBitDepth(16); err != nil {
+		t.Fatal(err)
+	}
+	g, err = Gain(c, 0, OverflowClamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := readAllFrames(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.BitDepth() != 16 || frames[0][0] != 1<<12 || frames[1][0] != -3<<8 {
+		t.Fatalf("expected rescaled 16-bit samples, got %d-bit %v", g.BitDepth(), frames)
+	}
+}
+
+func TestTransformsRejectUnsupportedFormats(t *testing.T) {
+	for _, info := range []audio.FrameInfo{
+		{Channels: 1, BitDepth: 12, SampleRate: 44100},
+		{Channels: 1, BitDepth: 16, SampleRate: 0},
+		{Channels: 1, BitDepth: 40, SampleRate: 44100},
+	} {
+		c := &Clip{r: bytes.NewReader(make([]byte, 8)), size: 8, channels: info.Channels, bitDepth: info.BitDepth, sampleRate: info.SampleRate}
+		if _, err := Gain(c, 3, OverflowClamp); err == nil {
+			t.Fatalf("expected Gain to reject %s", info)
+		}
+		if _, err := Resample(c, 22050); err == nil {
+			t.Fatalf("expected Resample, which low-pass filters, to reject %s", info)
+		}
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}
+	tests := []struct {
+		overflow Overflow
+		// expected is the sample 100 amplified by 2, nil for an error
+		expected []int
+	}{
+		{OverflowClamp, []int{127}},
+		{OverflowWrap, []int{200 - 256}},
+		{OverflowError, nil},
+	}
+	for _, tt := range tests {
+		gain, err := Gain(newMemClip([][]int{{100}}, info), 20*math.Log10(2), tt.overflow)
+		// the peak of 100/128 goes to 200/128, i.e. 20*log10(200/128) dBFS
+		normalized, nerr := NormalizeBatch([]audio.Clip{newMemClip([][]int{{100}}, info)}, 20*math.Log10(200.0/128), tt.overflow)
+		if tt.expected == nil {
+			if err == nil || !strings.Contains(err.Error(), ErrOverflow.Error()) {
+				t.Fatalf("Gain: expected an overflow error, got %v", err)
+			}
+			if nerr == nil || !strings.Contains(nerr.Error(), ErrOverflow.Error()) {
+				t.Fatalf("NormalizeBatch: expected an overflow error, got %v", nerr)
+			}
+			continue
+		}
+		if err != nil || nerr != nil {
+			t.Fatal(err, nerr)
+		}
+		for name, c := range map[string]audio.Clip{"Gain": gain, "NormalizeBatch": normalized[0]} {
+			frames, err := readAllFrames(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if frames[0][0] != tt.expected[0] {
+				t.Fatalf("%s with policy %d: expected %d, got %d", name, tt.overflow, tt.expected[0], frames[0][0])
+			}
+		}
+	}
+}
+
+func TestResampleAntiAlias(t *testing.T) {
+	// a 3kHz tone at 8kHz is above the 2kHz Nyquist frequency of 4kHz
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	frames := make([][]int, 8000)
+	for i := range frames {
+		frames[i] = []int{int(16000 * math.Sin(2*math.Pi*3000*float64(i)/8000))}
+	}
+	level := func(opts ...ResampleOption) float64 {
+		c, err := Resample(newMemClip(frames, info), 4000, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sum float64
+		// skip the edges of the filter
+		for _, frame := range out[100 : len(out)-100] {
+			sum += float64(frame[0]) * float64(frame[0])
+		}
+		return math.Sqrt(sum / float64(len(out)-200))
+	}
+	filtered, unfiltered := level(), level(NoAntiAlias)
+	if unfiltered < 5000 {
+		t.Fatalf("expected the unfiltered tone to alias at a high level, got an RMS of %.0f", unfiltered)
+	}
+	if filtered > unfiltered/10 {
+		t.Fatalf("expected the tone to be attenuated by 20dB, got an RMS of %.0f vs %.0f", filtered, unfiltered)
+	}
+}
+
+func TestNormalizeBatch(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	loud := newMemClip([][]int{{8000}, {-16000}, {4000}}, info)
+	quiet := newMemClip([][]int{{2000}, {-1000}}, info)
+	// -6dBFS is a peak of about 16423
+	out, err := NormalizeBatch([]audio.Clip{loud, quiet}, -6, OverflowClamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gain := audio.DBToAmplitude(-6) * 32768 / 16000
+	for i, expected := range [][]int{{8000, -16000, 4000}, {2000, -1000}} {
+		frames, err := readAllFrames(out[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, frame := range frames {
+			if want := int(math.Floor(float64(expected[j])*gain + 0.5)); frame[0] != want {
+				t.Fatalf("clip %d frame %d: expected %d, got %d", i, j, want, frame[0])
+			}
+		}
+	}
+	peak, _ := readAllFrames(out[0])
+	if db := audio.AmplitudeToDB(math.Abs(float64(peak[1][0])) / 32768); math.Abs(db+6) > 0.01 {
+		t.Fatalf("expected the loudest clip to peak at -6dBFS, got %.3f", db)
+	}
+}
+
+func TestResampleQualitySNR(t *testing.T) {
+	const srcRate, dstRate, tone = 44100, 16000, 5000.0
+	info := audio.FrameInfo{Channels: 1, BitDepth: 24, SampleRate: srcRate}
+	frames := make([][]int, srcRate/2)
+	for i := range frames {
+		frames[i] = []int{int(4000000 * math.Sin(2*math.Pi*tone*float64(i)/srcRate))}
+	}
+	snr := func(mode InterpMode) float64 {
+		c, err := ResampleQuality(newMemClip(frames, info), dstRate, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var signal, noise float64
+		// skip the edges of the kernels
+		for i := 100; i < len(out)-100; i++ {
+			expected := 4000000 * math.Sin(2*math.Pi*tone*float64(i)/dstRate)
+			signal += expected * expected
+			noise += (float64(out[i][0]) - expected) * (float64(out[i][0]) - expected)
+		}
+		return 10 * math.Log10(signal/noise)
+	}
+	linear, sinc := snr(Linear), snr(Sinc)
+	if sinc < linear+10 {
+		t.Fatalf("expected Sinc to improve the SNR by 10dB, got %.1fdB vs %.1fdB for Linear", sinc, linear)
+	}
+}
+
+func TestInt16Mono(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 44100}
+	frames := make([][]int, 44100)
+	for i := range frames {
+		v := int(1000000 * math.Sin(2*math.Pi*440*float64(i)/44100))
+		frames[i] = []int{v, v / 2}
+	}
+	samples, err := newMemClip(frames, info).Int16Mono(16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 16000 {
+		t.Fatalf("expected 1s at 16kHz, got %d samples", len(samples))
+	}
+	var peak int
+	for _, v := range samples {
+		if a := int(v); a > peak {
+			peak = a
+		} else if -a > peak {
+			peak = -a
+		}
+	}
+	if peak < 32000 {
+		t.Fatalf("expected samples normalized to the int16 full scale, got a peak of %d", peak)
+	}
+}
+
+func TestPan(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	frames := [][]int{{10000, 10000}, {-8000, -8000}, {0, 0}}
+	for _, tt := range []struct {
+		position    float64
+		left, right float64
+	}{
+		{0, 1, 1},
+		{-1, math.Sqrt2, 0},
+		{1, 0, math.Sqrt2},
+	} {
+		c, err := Pan(newMemClip(frames, info), tt.position)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, frame := range out {
+			l, r := float64(frames[i][0])*tt.left, float64(frames[i][1])*tt.right
+			if math.Abs(float64(frame[0])-l) > 1 || math.Abs(float64(frame[1])-r) > 1 {
+				t.Fatalf("pan %v: frame %d expected [%.0f %.0f], got %v", tt.position, i, l, r, frame)
+			}
+		}
+	}
+
+	if _, err := Pan(newMemClip([][]int{{1}}, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}), 0); err == nil {
+		t.Fatal("expected an error panning a mono clip")
+	}
+	if _, err := Pan(newMemClip(frames, info), 1.5); err == nil {
+		t.Fatal("expected an error panning out of range")
+	}
+}
+
+func TestInvertPhase(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	frames := [][]int{{1000, -2000}, {-32768, 32767}, {0, -32768}}
+	for _, tt := range []struct {
+		channels []int
+		expected [][]int
+	}{
+		{nil, [][]int{{-1000, 2000}, {32767, -32767}, {0, 32767}}},
+		{[]int{1}, [][]int{{1000, 2000}, {-32768, -32767}, {0, 32767}}},
+	} {
+		c, err := InvertPhase(newMemClip(frames, info), tt.channels...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, tt.expected) {
+			t.Fatalf("channels %v: expected %v, got %v", tt.channels, tt.expected, out)
+		}
+	}
+	if _, err := InvertPhase(newMemClip(frames, info), 2); err == nil {
+		t.Fatal("expected an error inverting a missing channel")
+	}
+}
+
+func TestChannelGain(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	frames := [][]int{{1000, 1000}, {-2000, -2000}, {30000, 100}}
+	c, err := ChannelGain(newMemClip(frames, info), []float64{6.0206, -6.0206})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := readAllFrames(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// +6dB doubles the left channel, clamped, -6dB halves the right one
+	if expected := [][]int{{2000, 500}, {-4000, -1000}, {32767, 50}}; !reflect.DeepEqual(out, expected) {
+		t.Fatalf("expected %v, got %v", expected, out)
+	}
+	if _, err := ChannelGain(newMemClip(frames, info), []float64{0}); err == nil {
+		t.Fatal("expected an error passing a single gain for a stereo clip")
+	}
+}
+
+func TestResampleRatio(t *testing.T) {
+	for _, n := range []int{48000, 4807} {
+		frames := make([][]int, n)
+		for i := range frames {
+			frames[i] = []int{10000, -5000}
+		}
+		c, err := ResampleRatio(newMemClip(frames, audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 48000}), 147, 160)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.SampleRate() != 44100 {
+			t.Fatalf("expected 44100Hz, got %dHz", c.SampleRate())
+		}
+		out, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := n * 147 / 160; len(out) != expected {
+			t.Fatalf("%d frames: expected %d resampled frames, got %d", n, expected, len(out))
+		}
+		// away from the edges, the filter keeps the level of the signal
+		for i := 100; i < len(out)-100; i++ {
+			if out[i][0] < 9990 || out[i][0] > 10010 || out[i][1] < -5010 || out[i][1] > -4990 {
+				t.Fatalf("%d frames: frame %d is %v, expected [10000 -5000]", n, i, out[i])
+			}
+		}
+	}
+
+	c := newMemClip([][]int{{0}}, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 44100})
+	if _, err := ResampleRatio(c, 0, 1); err == nil {
+		t.Fatal("expected an error for a zero ratio")
+	}
+	if _, err := ResampleRatio(c, 147, 160); err == nil {
+		t.Fatal("expected an error for a fractional sample rate")
+	}
+}
+
+func TestStereoWidth(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	frames := [][]int{{1000, 3000}, {-2000, 2000}, {32000, 30000}, {0, -5}}
+	for _, tt := range []struct {
+		width    float64
+		expected [][]int
+	}{
+		{0, [][]int{{2000, 2000}, {0, 0}, {31000, 31000}, {-2, -2}}},
+		{1, frames},
+		// the side component is doubled, clamped where it overflows
+		{2, [][]int{{0, 4000}, {-4000, 4000}, {32767, 29000}, {3, -7}}},
+	} {
+		c, err := StereoWidth(newMemClip(frames, info), tt.width)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := readAllFrames(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, tt.expected) {
+			t.Fatalf("width %v: expected %v, got %v", tt.width, tt.expected, out)
+		}
+	}
+	if _, err := StereoWidth(newMemClip([][]int{{1}}, audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}), 1); err == nil {
+		t.Fatal("expected an error for a mono clip")
+	}
+}
+
+func TestReadFrameInterp(t *testing.T) {
+	c := newMemClip([][]int{{0, 100}, {1000, -100}, {3000, 300}}, audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000})
+	for _, tt := range []struct {
+		pos      float64
+		mode     InterpMode
+		expected []int
+	}{
+		{0.5, Linear, []int{500, 0}},
+		{1.25, Linear, []int{1500, 0}},
+		{0.4, Nearest, []int{0, 100}},
+		{0.6, Nearest, []int{1000, -100}},
+		{1.75, Nearest, []int{3000, 300}},
+		{2, Linear, []int{3000, 300}},
+		{1, Nearest, []int{1000, -100}},
+	} {
+		frame, err := c.ReadFrameInterp(tt.pos, tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(frame, tt.expected) {
+			t.Fatalf("%v with mode %d: expected %v, got %v", tt.pos, tt.mode, tt.expected, frame)
+		}
+	}
+	for _, pos := range []float64{-0.5, 2.5, math.NaN()} {
+		if _, err := c.ReadFrameInterp(pos, Linear); err == nil {
+			t.Fatalf("expected an error reading position %v", pos)
+		}
+	}
+}
+
+func TestSwapChannels(t *testing.T) {
+	info := audio.FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 8000}
+	frames := [][]int{{1, -1}, {200, 300}, {-32768, 32767}}
+	c, err := SwapChannels(newMemClip(frames, info), 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := readAllFrames(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]int{{-1, 1}, {300, 200}, {32767, -32768}}; !reflect.DeepEqual(out, expected) {
+		t.Fatalf("expected %v, got %v", expected, out)
+	}
+	for _, chans := range [][2]int{{0, 2}, {-1, 1}} {
+		if _, err := SwapChannels(newMemClip(frames, info), chans[0], chans[1]); err == nil {
+			t.Fatalf("expected an error swapping channels %v", chans)
+		}
+	}
+}
+
+func TestDownmixMatrix(t *testing.T) {
+	info := audio.FrameInfo{Channels: 6, BitDepth: 16, SampleRate: 48000}
+	// L, R, C, LFE, Ls, Rs
+	frames := [][]int{
+		{0, 0, 10000, 0, 0, 0},
+		{1000, 2000, 0, 5000, 0, 0},
+		{0, 0, 0, 0, 3000, -3000},
+	}
+	matrix := [][]float64{
+		{1, 0, 0.707, 0, 0.707, 0},
+		{0, 1, 0.707, 0, 0, 0.707},
+	}
+	c, err := DownmixMatrix(newMemClip(frames, info), matrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Channels() != 2 {
+		t.Fatalf("expected a stereo clip, got %d channels", c.Channels())
+	}
+	out, err := readAllFrames(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the center contributes to both outputs, the LFE to none
+	if expected := [][]int{{7070, 7070}, {1000, 2000}, {2121, -2121}}; !reflect.DeepEqual(out, expected) {
+		t.Fatalf("expected %v, got %v", expected, out)
+	}
+
+	for _, bad := range [][][]float64{nil, {{1, 0, 0, 0, 0}}} {
+		if _, err := DownmixMatrix(newMemClip(frames, info), bad); err == nil {
+			t.Fatalf("expected an error for the matrix %v", bad)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package audio
 
-import "io"
+import (
+	"errors"
+	"io"
+	"strconv"
+)
 
 // FrameInfo represents the frame-level information.
 type FrameInfo struct {
@@ -15,17 +19,152 @@ type FrameInfo struct {
 	SampleRate int64
 }
 
+// String returns a short description of the frame info such as
+// "2ch 16-bit 44100Hz".
+func (fi FrameInfo) String() string {
+	b := make([]byte, 0, 24)
+	b = strconv.AppendInt(b, int64(fi.Channels), 10)
+	b = append(b, "ch "...)
+	b = strconv.AppendInt(b, int64(fi.BitDepth), 10)
+	b = append(b, "-bit "...)
+	b = strconv.AppendInt(b, fi.SampleRate, 10)
+	b = append(b, "Hz"...)
+	return string(b)
+}
+
+// BytesPerFrame returns the number of bytes used to store a frame, samples
+// being padded to the next byte boundary.
+func (fi FrameInfo) BytesPerFrame() int {
+	return (fi.BitDepth + 7) / 8 * fi.Channels
+}
+
+// SSNDSize returns the number of bytes taken by the payload of an AIFF SSND
+// chunk holding the passed amount of frames: the offset and block size
+// fields, the sound data and the pad byte following odd sized data.
+func (fi FrameInfo) SSNDSize(frames int64) int64 {
+	size := 8 + frames*int64(fi.BytesPerFrame())
+	return size + size%2
+}
+
+// Validate returns an error describing the first invalid field of the frame
+// info: the channel count and the sample rate must be positive and the bit
+// depth between 1 and 32.
+func (fi FrameInfo) Validate() error {
+	if fi.Channels < 1 {
+		return errors.New("invalid channel count " + strconv.Itoa(fi.Channels))
+	}
+	if fi.BitDepth < 1 || fi.BitDepth > 32 {
+		return errors.New("unsupported bit depth " + strconv.Itoa(fi.BitDepth))
+	}
+	if fi.SampleRate < 1 {
+		return errors.New("invalid sample rate " + strconv.FormatInt(fi.SampleRate, 10))
+	}
+	return nil
+}
+
+// SameFormat reports whether a and b describe the same channel count,
+// bit depth and sample rate. aiff.Insert uses it to reject clips whose
+// frames can't be spliced together.
+func SameFormat(a, b FrameInfo) bool {
+	return a.Channels == b.Channels &&
+		a.BitDepth == b.BitDepth &&
+		a.SampleRate == b.SampleRate
+}
+
+// RequireSameRate returns an error naming the clips whose sample rate
+// differs from the first clip's, for instance:
+// "sample rate mismatch: clip 0 is 44100Hz, clip 2 is 48000Hz".
+// It is meant to be called before mixing or concatenating clips.
+func RequireSameRate(clips ...Clip) error {
+	if len(clips) < 2 {
+		return nil
+	}
+	rate := clips[0].SampleRate()
+	b := []byte("sample rate mismatch: clip 0 is ")
+	b = strconv.AppendInt(b, rate, 10)
+	b = append(b, "Hz"...)
+	var mismatch bool
+	for i, c := range clips[1:] {
+		if c.SampleRate() == rate {
+			continue
+		}
+		mismatch = true
+		b = append(b, ", clip "...)
+		b = strconv.AppendInt(b, int64(i+1), 10)
+		b = append(b, " is "...)
+		b = strconv.AppendInt(b, c.SampleRate(), 10)
+		b = append(b, "Hz"...)
+	}
+	if !mismatch {
+		return nil
+	}
+	return errors.New(string(b))
+}
+
+// SampleFormat describes how the samples of a clip are encoded in its
+// source.
+type SampleFormat int
+
+const (
+	// UnknownFormat is used when the encoding isn't recognized.
+	UnknownFormat SampleFormat = iota
+	// PCMS8 is signed 8-bit PCM.
+	PCMS8
+	// PCMS16BE, PCMS24BE and PCMS32BE are signed big endian PCM, samples
+	// of other bit depths being padded to the next byte.
+	PCMS16BE
+	PCMS24BE
+	PCMS32BE
+	// PCMS16LE, PCMS24LE and PCMS32LE are signed little endian PCM.
+	PCMS16LE
+	PCMS24LE
+	PCMS32LE
+	// Float32BE and Float64BE are big endian IEEE floats.
+	Float32BE
+	Float64BE
+	// ULAW and ALAW are G.711 companded samples.
+	ULAW
+	ALAW
+)
+
+var sampleFormatNames = [...]string{
+	UnknownFormat: "unknown",
+	PCMS8:         "PCM S8",
+	PCMS16BE:      "PCM S16BE",
+	PCMS24BE:      "PCM S24BE",
+	PCMS32BE:      "PCM S32BE",
+	PCMS16LE:      "PCM S16LE",
+	PCMS24LE:      "PCM S24LE",
+	PCMS32LE:      "PCM S32LE",
+	Float32BE:     "float32 BE",
+	Float64BE:     "float64 BE",
+	ULAW:          "u-law",
+	ALAW:          "A-law",
+}
+
+func (f SampleFormat) String() string {
+	if f < 0 || int(f) >= len(sampleFormatNames) {
+		return sampleFormatNames[UnknownFormat]
+	}
+	return sampleFormatNames[f]
+}
+
 // Clip represents a linear PCM formatted audio io.ReadSeeker.
 // Clip can seek and read from a section and allow users to
 // consume a small section of the underlying audio data.
 //
 // FrameInfo returns the basic frame-level information about the clip audio.
+// Channels, SampleRate and BitDepth are shortcuts to the matching FrameInfo
+// fields.
 //
 // Size returns the total number of bytes of the underlying audio data.
 // TODO(jbd): Support cases where size is unknown?
 type Clip interface {
 	io.ReadSeeker
 	FrameInfo() FrameInfo
+	Channels() int
+	SampleRate() int64
+	BitDepth() int
 	Size() int64
 }
 
@@ -57,3 +196,24 @@ func IeeeFloatToInt(b [10]byte) int {
 
 	return int(i)
 }
+
+// IntToIeeeFloat converts an int into a 10 byte IEEE float.
+func IntToIeeeFloat(i int) [10]byte {
+	var b [10]byte
+	if i <= 0 {
+		return b
+	}
+	// highest bit set
+	var bits uint
+	for v := uint64(i); v > 0; v >>= 1 {
+		bits++
+	}
+	exponent := uint16(16383 + bits - 1)
+	mantissa := uint64(i) << (64 - bits)
+	b[0] = byte(exponent >> 8)
+	b[1] = byte(exponent)
+	for j := 0; j < 8; j++ {
+		b[2+j] = byte(mantissa >> (56 - 8*uint(j)))
+	}
+	return b
+}
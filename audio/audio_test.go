@@ -0,0 +1,195 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestSameFormat(t *testing.T) {
+	a := FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	tests := []struct {
+		name string
+		b    FrameInfo
+		same bool
+	}{
+		{"same", FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}, true},
+		{"channels", FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 44100}, false},
+		{"bit depth", FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 44100}, false},
+		{"sample rate", FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 48000}, false},
+	}
+	for _, tt := range tests {
+		if got := SameFormat(a, tt.b); got != tt.same {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.same, got)
+		}
+		if got := SameFormat(tt.b, a); got != tt.same {
+			t.Errorf("%s (swapped): expected %v, got %v", tt.name, tt.same, got)
+		}
+	}
+}
+
+func TestFrameInfoString(t *testing.T) {
+	for _, tt := range []struct {
+		info     FrameInfo
+		expected string
+	}{
+		{FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}, "2ch 16-bit 44100Hz"},
+		{FrameInfo{Channels: 1, BitDepth: 24, SampleRate: 96000}, "1ch 24-bit 96000Hz"},
+	} {
+		if got := tt.info.String(); got != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, got)
+		}
+	}
+}
+
+func TestFrameInfoStringAllocs(t *testing.T) {
+	info := FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}
+	// the byte buffer and the returned string
+	if n := testing.AllocsPerRun(100, func() { _ = info.String() }); n > 2 {
+		t.Fatalf("expected at most 2 allocations, got %v", n)
+	}
+}
+
+// rateClip is a clip only answering its sample rate.
+type rateClip struct {
+	Clip
+	rate int64
+}
+
+func (c rateClip) SampleRate() int64 { return c.rate }
+
+func TestRequireSameRate(t *testing.T) {
+	if err := RequireSameRate(rateClip{rate: 44100}, rateClip{rate: 44100}, rateClip{rate: 44100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RequireSameRate(rateClip{rate: 44100}); err != nil {
+		t.Fatal(err)
+	}
+	err := RequireSameRate(rateClip{rate: 44100}, rateClip{rate: 44100}, rateClip{rate: 48000}, rateClip{rate: 22050})
+	expected := "sample rate mismatch: clip 0 is 44100Hz, clip 2 is 48000Hz, clip 3 is 22050Hz"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestOpenCAF(t *testing.T) {
+	caf := []byte("caff\x00\x01\x00\x00desc")
+	if f, ok := Sniff(caf); !ok || f != FormatCAF {
+		t.Fatalf("expected CAF to be sniffed, got %s", f)
+	}
+	r := bytes.NewReader(caf)
+	c, name, err := Open(r)
+	if err != ErrCAFNotSupported {
+		t.Fatalf("expected ErrCAFNotSupported, got %v", err)
+	}
+	if c != nil || name != "caf" {
+		t.Fatalf("expected no clip and the caf format, got %v and %q", c, name)
+	}
+	if pos, _ := r.Seek(0, io.SeekCurrent); pos != 0 {
+		t.Fatalf("expected the read position to be restored, got %d", pos)
+	}
+
+	if _, _, err := Open(bytes.NewReader([]byte("OggS\x00\x02"))); err != ErrFormat {
+		t.Fatalf("expected ErrFormat for unknown data, got %v", err)
+	}
+}
+
+func TestFrameInfoValidate(t *testing.T) {
+	for _, tt := range []struct {
+		info FrameInfo
+		err  string
+	}{
+		{FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}, ""},
+		{FrameInfo{Channels: 1, BitDepth: 1, SampleRate: 1}, ""},
+		{FrameInfo{Channels: 6, BitDepth: 32, SampleRate: 192000}, ""},
+		{FrameInfo{Channels: 0, BitDepth: 16, SampleRate: 44100}, "invalid channel count 0"},
+		{FrameInfo{Channels: -1, BitDepth: 16, SampleRate: 44100}, "invalid channel count -1"},
+		{FrameInfo{Channels: 2, BitDepth: 0, SampleRate: 44100}, "unsupported bit depth 0"},
+		{FrameInfo{Channels: 2, BitDepth: 33, SampleRate: 44100}, "unsupported bit depth 33"},
+		{FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 0}, "invalid sample rate 0"},
+	} {
+		err := tt.info.Validate()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.info, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: expected the error %q, got %v", tt.info, tt.err, err)
+		}
+	}
+}
+
+func TestSniff(t *testing.T) {
+	for _, tt := range []struct {
+		data     string
+		expected Format
+		ok       bool
+	}{
+		{"FORM\x00\x00\x10\x00AIFFCOMM", FormatAIFF, true},
+		{"FORM\x00\x00\x10\x00AIFC", FormatAIFC, true},
+		{"RIFF\x24\x00\x00\x00WAVEfmt ", FormatWAV, true},
+		{"RIFX\x00\x00\x00\x24WAVE", FormatWAV, true},
+		{"RF64\xff\xff\xff\xffWAVE", FormatWAV, true},
+		{"caff\x00\x01\x00\x00", FormatCAF, true},
+		{"FORM\x00\x00\x10\x00WAVE", FormatUnknown, false},
+		{"RIFF\x24\x00\x00\x00AVI ", FormatUnknown, false},
+		{"FORM\x00\x00", FormatUnknown, false},
+		{"", FormatUnknown, false},
+	} {
+		f, ok := Sniff([]byte(tt.data))
+		if f != tt.expected || ok != tt.ok {
+			t.Errorf("%q: expected %s (%v), got %s (%v)", tt.data, tt.expected, tt.ok, f, ok)
+		}
+	}
+}
+
+func TestFrameInfoSSNDSize(t *testing.T) {
+	for _, tt := range []struct {
+		info     FrameInfo
+		frames   int64
+		expected int64
+	}{
+		{FrameInfo{Channels: 2, BitDepth: 16, SampleRate: 44100}, 100, 8 + 400},
+		{FrameInfo{Channels: 2, BitDepth: 24, SampleRate: 48000}, 10, 8 + 60},
+		{FrameInfo{Channels: 1, BitDepth: 12, SampleRate: 8000}, 3, 8 + 6},
+		{FrameInfo{Channels: 1, BitDepth: 32, SampleRate: 8000}, 0, 8},
+		// odd sized data is followed by a pad byte
+		{FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}, 3, 8 + 3 + 1},
+		{FrameInfo{Channels: 3, BitDepth: 24, SampleRate: 8000}, 1, 8 + 9 + 1},
+		{FrameInfo{Channels: 1, BitDepth: 8, SampleRate: 8000}, 4, 8 + 4},
+	} {
+		if size := tt.info.SSNDSize(tt.frames); size != tt.expected {
+			t.Errorf("%s, %d frames: expected %d bytes, got %d", tt.info, tt.frames, tt.expected, size)
+		}
+	}
+}
+
+func TestDecibels(t *testing.T) {
+	for _, tt := range []struct {
+		amplitude, db float64
+	}{
+		{1, 0},
+		{0.5, -6.0206},
+		{2, 6.0206},
+		{0.1, -20},
+		{0.001, -60},
+	} {
+		if db := AmplitudeToDB(tt.amplitude); math.Abs(db-tt.db) > 1e-4 {
+			t.Errorf("AmplitudeToDB(%v): expected %v, got %v", tt.amplitude, tt.db, db)
+		}
+		if a := DBToAmplitude(tt.db); math.Abs(a-tt.amplitude) > 1e-4*tt.amplitude {
+			t.Errorf("DBToAmplitude(%v): expected %v, got %v", tt.db, tt.amplitude, a)
+		}
+	}
+	if db := AmplitudeToDB(-0.5); math.Abs(db+6.0206) > 1e-4 {
+		t.Errorf("expected a negative amplitude to use its magnitude, got %v", db)
+	}
+	for _, a := range []float64{0, 1e-9, math.NaN()} {
+		if db := AmplitudeToDB(a); db != MinDB {
+			t.Errorf("AmplitudeToDB(%v): expected the %v floor, got %v", a, MinDB, db)
+		}
+	}
+}
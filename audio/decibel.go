@@ -0,0 +1,22 @@
+package audio
+
+import "math"
+
+// MinDB is the level AmplitudeToDB returns for silence, instead of -Inf.
+const MinDB = -120.0
+
+// AmplitudeToDB converts a linear amplitude, 1 being full scale, to
+// decibels. Amplitudes below MinDB, including 0, return MinDB.
+func AmplitudeToDB(a float64) float64 {
+	db := 20 * math.Log10(math.Abs(a))
+	if db < MinDB || math.IsNaN(db) {
+		return MinDB
+	}
+	return db
+}
+
+// DBToAmplitude converts decibels to a linear amplitude, 0 dB being full
+// scale.
+func DBToAmplitude(db float64) float64 {
+	return math.Pow(10, db/20)
+}
@@ -0,0 +1,91 @@
+// Command waveform draws the waveform overview of an AIFF file as a PNG
+// image.
+//
+//	waveform -width 800 -height 200 -o out.png song.aif
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/mattetti/exp/audio/aiff"
+)
+
+var (
+	width  = flag.Int("width", 800, "width of the image in pixels")
+	height = flag.Int("height", 200, "height of the image in pixels")
+	output = flag.String("o", "waveform.png", "path of the PNG file to write")
+)
+
+var (
+	background = color.RGBA{0x20, 0x20, 0x20, 0xff}
+	foreground = color.RGBA{0x40, 0xc0, 0xff, 0xff}
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: waveform [flags] file.aif\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *width < 1 || *height < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	c, err := aiff.Decode(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	buckets, err := aiff.Waveform(c, *width)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := png.Encode(out, render(buckets, *height)); err != nil {
+		out.Close()
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// render draws one column per bucket, from its minimum to its maximum
+// amplitude, the center line being silence.
+func render(buckets [][2]float64, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(buckets), height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	// y grows downwards, amplitudes upwards
+	toY := func(v float64) int {
+		y := int((1 - v) / 2 * float64(height-1))
+		if y < 0 {
+			return 0
+		}
+		if y >= height {
+			return height - 1
+		}
+		return y
+	}
+	for x, b := range buckets {
+		for y := toY(b[1]); y <= toY(b[0]); y++ {
+			img.SetRGBA(x, y, foreground)
+		}
+	}
+	return img
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/mattetti/exp/audio"
+	"github.com/mattetti/exp/audio/aiff"
+)
+
+func TestRender(t *testing.T) {
+	info := audio.FrameInfo{Channels: 1, BitDepth: 16, SampleRate: 8000}
+	data := make([]byte, 2*8000)
+	for i := 0; i < len(data)/2; i++ {
+		v := int16(20000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+		data[2*i], data[2*i+1] = byte(uint16(v)>>8), byte(v)
+	}
+	c := aiff.RawClip(bytes.NewReader(data), info, int64(len(data)))
+	buckets, err := aiff.Waveform(c, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, render(buckets, 40)); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 120 || b.Dy() != 40 {
+		t.Fatalf("expected a 120x40 image, got %dx%d", b.Dx(), b.Dy())
+	}
+	var drawn int
+	for x := 0; x < 120; x++ {
+		for y := 0; y < 40; y++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			fr, fg, fb, _ := foreground.RGBA()
+			if r == fr && g == fg && b == fb {
+				drawn++
+			}
+		}
+	}
+	// the tone peaks at about 60% of the full scale
+	if drawn < 120*40/2 {
+		t.Fatalf("expected the waveform to cover most of the image, %d pixels drawn", drawn)
+	}
+}
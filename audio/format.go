@@ -0,0 +1,128 @@
+package audio
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrFormat indicates that the data doesn't match any registered format.
+	ErrFormat = errors.New("audio: unknown format")
+	// ErrCAFNotSupported is returned by Open for Core Audio Format files,
+	// which are recognized but can't be decoded yet.
+	ErrCAFNotSupported = errors.New("audio: CAF format not yet supported")
+)
+
+// Format identifies a container format.
+type Format int
+
+const (
+	// FormatUnknown is returned when the container isn't recognized.
+	FormatUnknown Format = iota
+	FormatAIFF
+	FormatAIFC
+	FormatWAV
+	FormatCAF
+)
+
+var formatNames = [...]string{
+	FormatUnknown: "unknown",
+	FormatAIFF:    "aiff",
+	FormatAIFC:    "aifc",
+	FormatWAV:     "wav",
+	FormatCAF:     "caf",
+}
+
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return formatNames[FormatUnknown]
+	}
+	return formatNames[f]
+}
+
+// Sniff detects the container format from the first bytes of a file, up to
+// 12 bytes being inspected. The boolean reports whether the format was
+// recognized.
+func Sniff(b []byte) (Format, bool) {
+	switch {
+	case match("FORM????AIFF", b):
+		return FormatAIFF, true
+	case match("FORM????AIFC", b):
+		return FormatAIFC, true
+	case match("RIFF????WAVE", b), match("RIFX????WAVE", b), match("RF64????WAVE", b):
+		return FormatWAV, true
+	case match("caff", b):
+		return FormatCAF, true
+	}
+	return FormatUnknown, false
+}
+
+// format holds a registered decoder and the magic identifying its data.
+type format struct {
+	name   string
+	magic  string
+	decode func(io.ReadSeeker) (Clip, error)
+}
+
+var (
+	formatsMu sync.Mutex
+	formats   []format
+)
+
+// RegisterFormat registers a format for use by Open. Name is the name of the
+// format, like "aiff". Magic is the prefix identifying the encoded data, a
+// '?' matching any byte. Decode is the function decoding the data.
+// Decoder packages usually call RegisterFormat in their init function so
+// importing them is enough to make Open support their format.
+func RegisterFormat(name, magic string, decode func(io.ReadSeeker) (Clip, error)) {
+	formatsMu.Lock()
+	formats = append(formats, format{name: name, magic: magic, decode: decode})
+	formatsMu.Unlock()
+}
+
+// Open sniffs the format of the data of r, starting at its current position,
+// and decodes it using the matching registered format. The returned string
+// is the name of the format.
+func Open(r io.ReadSeeker) (Clip, string, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, "", err
+	}
+	b := make([]byte, 12)
+	n, err := io.ReadFull(r, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, "", err
+	}
+	b = b[:n]
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	if f, _ := Sniff(b); f == FormatCAF {
+		return nil, f.String(), ErrCAFNotSupported
+	}
+	formatsMu.Lock()
+	registered := formats
+	formatsMu.Unlock()
+	for _, f := range registered {
+		if match(f.magic, b) {
+			c, err := f.decode(r)
+			return c, f.name, err
+		}
+	}
+	return nil, "", ErrFormat
+}
+
+// match reports whether b starts with magic, '?' matching any byte.
+func match(magic string, b []byte) bool {
+	if len(magic) > len(b) {
+		return false
+	}
+	for i, c := range b[:len(magic)] {
+		if magic[i] != c && magic[i] != '?' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,7 @@
+package audio
+
+// Int8Sample converts a signed 8-bit PCM sample, as stored in AIFF files,
+// into the common signed sample representation.
+func Int8Sample(b byte) int {
+	return int(int8(b))
+}